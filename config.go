@@ -0,0 +1,414 @@
+package logit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DispatchConfig 按规则分发到多个文件的配置，对应 NewWithDispatch 的参数。
+// 设置后日志写入 Config.Filename 加各规则后缀的文件，lumberjack 相关的配置和 ToStdout 不再生效。
+type DispatchConfig struct {
+	// RuleName 切分规则，例如 1hour、1day、no，见 NewWithDispatch
+	RuleName string `json:"rule_name"`
+	// Rules 分发规则，每条规则将指定级别的日志写入对应后缀的文件
+	Rules []DispatchRule `json:"rules"`
+	// MaxFileNum 保留的文件数量，对应 WithMaxFileNum
+	MaxFileNum int `json:"max_file_num"`
+	// BufferSize 缓冲大小，对应 WithBufferSize
+	BufferSize int `json:"buffer_size"`
+	// FlushInterval 周期性写入磁盘的间隔，对应 WithFlushDuration，JSON 中为 "1s" 形式的字符串
+	FlushInterval time.Duration `json:"flush_interval"`
+	// CheckInterval 检查文件是否被删除的间隔，对应 WithCheckDuration，JSON 中为 "1s" 形式的字符串
+	CheckInterval time.Duration `json:"check_interval"`
+}
+
+// DispatchRule 对应 ZapDispatch 的可序列化配置
+type DispatchRule struct {
+	// FileSuffix 文件后缀，为空时写入 Config.Filename
+	FileSuffix string `json:"file_suffix"`
+	// Levels 写入该文件的日志级别，例如 ["warn","error"]
+	Levels []string `json:"levels"`
+	// Encoder 编码器，json 或 console，为空时使用 DefaultEncoder
+	Encoder string `json:"encoder"`
+}
+
+// UnmarshalJSON 耗时字段支持 "1s" 形式的字符串或纳秒数
+func (c *DispatchConfig) UnmarshalJSON(data []byte) error {
+	type plain DispatchConfig
+	var raw struct {
+		plain
+		FlushInterval json.RawMessage `json:"flush_interval"`
+		CheckInterval json.RawMessage `json:"check_interval"`
+	}
+	raw.plain = plain(*c)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	*c = DispatchConfig(raw.plain)
+
+	var err error
+	if c.FlushInterval, err = decodeDuration("dispatch.flush_interval", raw.FlushInterval, c.FlushInterval); err != nil {
+		return err
+	}
+	if c.CheckInterval, err = decodeDuration("dispatch.check_interval", raw.CheckInterval, c.CheckInterval); err != nil {
+		return err
+	}
+	return nil
+}
+
+func decodeDuration(key string, raw json.RawMessage, fallback time.Duration) (time.Duration, error) {
+	if len(raw) == 0 {
+		return fallback, nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return 0, fmt.Errorf("config %s: %w", key, err)
+	}
+	switch v := v.(type) {
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("config %s: %w", key, err)
+		}
+		return d, nil
+	case float64:
+		return time.Duration(v), nil
+	default:
+		return 0, fmt.Errorf("config %s: want duration string like \"1s\", got %s", key, raw)
+	}
+}
+
+// LoadConfig 从 JSON 文件读取配置，未知的配置项会返回错误。读取时不校验配置，
+// 单个来源可以只包含部分配置，合并全部来源后由 NewWithConfig 或 Validate 统一校验。
+// 多种来源的优先级从低到高为：代码中的默认值、JSON 文件、环境变量、命令行参数，例如：
+//
+//	cfg, err := logit.LoadConfig("log.json")
+//	err = cfg.LoadEnv("LOGIT_")
+//	cfg.RegisterFlags(flag.CommandLine)
+//	flag.Parse()
+//	logger, closeFn, err := logit.NewWithConfig(cfg)
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("load config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ConfigFromEnv 从环境变量读取配置，变量名见 LoadEnv，与 LoadConfig 一样读取时不校验
+func ConfigFromEnv(prefix string) (Config, error) {
+	var cfg Config
+	err := cfg.LoadEnv(prefix)
+	return cfg, err
+}
+
+// LoadEnv 使用环境变量覆盖已有的配置，未设置的变量不影响已有的值。
+// 变量名为 prefix 加大写的配置项，"." 替换为 "_"，例如 LOGIT_MAX_SIZE、LOGIT_DISPATCH_RULE_NAME，
+// 分发规则 LOGIT_DISPATCH_RULES 的值为 JSON 数组。
+func (c *Config) LoadEnv(prefix string) error {
+	var errs []error
+	for _, k := range configKeys {
+		name := prefix + strings.ToUpper(strings.ReplaceAll(k.name, ".", "_"))
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := k.set(c, v); err != nil {
+			errs = append(errs, fmt.Errorf("config %s (env %s): %w", k.name, name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterFlags 在 fs 中注册配置对应的命令行参数，默认值为当前配置，fs.Parse 之后写入配置。
+// 参数名为 log- 加配置项，"." 和 "_" 替换为 "-"，例如 -log-max-size、-log-dispatch-rule-name。
+// 解析完成后由 NewWithConfig 或 Validate 校验。
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	for _, k := range configKeys {
+		name := "log-" + strings.NewReplacer(".", "-", "_", "-").Replace(k.name)
+		fs.Var(&configFlag{cfg: c, key: k}, name, k.usage)
+	}
+}
+
+// configFlag 将配置项适配为 flag.Value
+type configFlag struct {
+	cfg *Config
+	key configKey
+}
+
+func (f *configFlag) String() string {
+	if f.cfg == nil {
+		return ""
+	}
+	return f.key.get(f.cfg)
+}
+
+func (f *configFlag) Set(v string) error {
+	return f.key.set(f.cfg, v)
+}
+
+// IsBoolFlag 布尔配置项支持 -log-compress 这种不带值的写法
+func (f *configFlag) IsBoolFlag() bool {
+	return f.key.bool
+}
+
+// configKey 可通过环境变量和命令行参数设置的配置项，name 与 JSON 中的 key 一致
+type configKey struct {
+	name  string
+	usage string
+	bool  bool
+	get   func(c *Config) string
+	set   func(c *Config, v string) error
+}
+
+// dispatch 返回分发配置，不存在时新建
+func (c *Config) dispatch() *DispatchConfig {
+	if c.Dispatch == nil {
+		c.Dispatch = &DispatchConfig{}
+	}
+	return c.Dispatch
+}
+
+func stringKey(name, usage string, field func(c *Config) *string) configKey {
+	return configKey{
+		name:  name,
+		usage: usage,
+		get:   func(c *Config) string { return *field(c) },
+		set: func(c *Config, v string) error {
+			*field(c) = v
+			return nil
+		},
+	}
+}
+
+func intKey(name, usage string, field func(c *Config) *int) configKey {
+	return configKey{
+		name:  name,
+		usage: usage,
+		get:   func(c *Config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid int %q", v)
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+func boolKey(name, usage string, field func(c *Config) *bool) configKey {
+	return configKey{
+		name:  name,
+		usage: usage,
+		bool:  true,
+		get:   func(c *Config) string { return strconv.FormatBool(*field(c)) },
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid bool %q", v)
+			}
+			*field(c) = b
+			return nil
+		},
+	}
+}
+
+func durationKey(name, usage string, field func(c *Config) *time.Duration) configKey {
+	return configKey{
+		name:  name,
+		usage: usage,
+		get:   func(c *Config) string { return field(c).String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", v)
+			}
+			*field(c) = d
+			return nil
+		},
+	}
+}
+
+// dispatchGetter 读取分发配置，未设置分发配置时返回零值，避免读取时创建
+func dispatchGetter[T any](field func(d *DispatchConfig) *T) func(c *Config) *T {
+	return func(c *Config) *T {
+		if c.Dispatch == nil {
+			var zero T
+			return &zero
+		}
+		return field(c.Dispatch)
+	}
+}
+
+// dispatchSetter 包装写入分发配置的函数，写入时创建分发配置
+func dispatchSetter(k configKey) configKey {
+	set := k.set
+	k.set = func(c *Config, v string) error {
+		c.dispatch()
+		return set(c, v)
+	}
+	return k
+}
+
+var configKeys = []configKey{
+	stringKey("filename", "log file name", func(c *Config) *string { return &c.Filename }),
+	intKey("max_size", "max size in MB before rotating", func(c *Config) *int { return &c.MaxSize }),
+	intKey("max_backups", "max number of old log files to keep", func(c *Config) *int { return &c.MaxBackups }),
+	intKey("max_age", "max days to keep old log files", func(c *Config) *int { return &c.MaxAge }),
+	boolKey("compress", "compress rotated log files", func(c *Config) *bool { return &c.Compress }),
+	stringKey("level", `log level spec, e.g. "info,db=debug"`, func(c *Config) *string { return &c.Level }),
+	boolKey("to_stdout", "also write logs to stdout", func(c *Config) *bool { return &c.ToStdout }),
+	dispatchSetter(stringKey("dispatch.rule_name", "dispatch rotate rule, e.g. 1hour, 1day, no",
+		dispatchGetter(func(d *DispatchConfig) *string { return &d.RuleName }))),
+	dispatchSetter(configKey{
+		name:  "dispatch.rules",
+		usage: `dispatch rules as JSON, e.g. [{"file_suffix":".wf","levels":["warn","error"]}]`,
+		get: func(c *Config) string {
+			if c.Dispatch == nil || len(c.Dispatch.Rules) == 0 {
+				return ""
+			}
+			data, _ := json.Marshal(c.Dispatch.Rules)
+			return string(data)
+		},
+		set: func(c *Config, v string) error {
+			var rules []DispatchRule
+			dec := json.NewDecoder(strings.NewReader(v))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&rules); err != nil {
+				return fmt.Errorf("invalid rules: %w", err)
+			}
+			c.Dispatch.Rules = rules
+			return nil
+		},
+	}),
+	dispatchSetter(intKey("dispatch.max_file_num", "number of dispatched files to keep",
+		dispatchGetter(func(d *DispatchConfig) *int { return &d.MaxFileNum }))),
+	dispatchSetter(intKey("dispatch.buffer_size", "dispatch writer buffer size",
+		dispatchGetter(func(d *DispatchConfig) *int { return &d.BufferSize }))),
+	dispatchSetter(durationKey("dispatch.flush_interval", "interval to flush buffered logs",
+		dispatchGetter(func(d *DispatchConfig) *time.Duration { return &d.FlushInterval }))),
+	dispatchSetter(durationKey("dispatch.check_interval", "interval to check whether log files were removed",
+		dispatchGetter(func(d *DispatchConfig) *time.Duration { return &d.CheckInterval }))),
+}
+
+// Validate 校验配置，返回的错误包含出错的配置项，多个错误一并返回
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("config %s: "+format, append([]any{key}, args...)...))
+	}
+
+	if _, err := ParseLevelSpec(c.Level); err != nil {
+		fail("level", "%v", err)
+	}
+	nonNegative := func(key string, v int64) {
+		if v < 0 {
+			fail(key, "must not be negative, got %d", v)
+		}
+	}
+	nonNegative("max_size", int64(c.MaxSize))
+	nonNegative("max_backups", int64(c.MaxBackups))
+	nonNegative("max_age", int64(c.MaxAge))
+
+	if d := c.Dispatch; d != nil {
+		if c.Filename == "" {
+			fail("filename", "required when dispatch is set")
+		}
+		if d.RuleName == "" {
+			fail("dispatch.rule_name", "required")
+		}
+		if len(d.Rules) == 0 {
+			fail("dispatch.rules", "at least one rule is required")
+		}
+		for i, rule := range d.Rules {
+			key := fmt.Sprintf("dispatch.rules[%d]", i)
+			if len(rule.Levels) == 0 {
+				fail(key+".levels", "at least one level is required")
+			}
+			for j, text := range rule.Levels {
+				var lvl zapcore.Level
+				if err := lvl.UnmarshalText([]byte(text)); err != nil {
+					fail(fmt.Sprintf("%s.levels[%d]", key, j), "%v", err)
+				}
+			}
+			if _, ok := configEncoders[rule.Encoder]; !ok {
+				fail(key+".encoder", "unknown encoder %q, want json or console", rule.Encoder)
+			}
+		}
+		nonNegative("dispatch.max_file_num", int64(d.MaxFileNum))
+		nonNegative("dispatch.buffer_size", int64(d.BufferSize))
+		nonNegative("dispatch.flush_interval", int64(d.FlushInterval))
+		nonNegative("dispatch.check_interval", int64(d.CheckInterval))
+	}
+	return errors.Join(errs...)
+}
+
+// configEncoders 分发规则可用的编码器。空字符串需要显式对应 DefaultEncoder，
+// BuildDispatchCore 中未指定编码器的规则会沿用上一条规则的编码器
+var configEncoders = map[string]EncoderBuilder{
+	"":        DefaultEncoder,
+	"json":    NewJSONEncoder(),
+	"console": NewConsoleEncoder(),
+}
+
+// NewWithConfig 校验配置后构建 Logger：设置了 Dispatch 时使用 NewWithDispatch 按规则分发，否则使用 New。
+// 使用 Dispatch 且 Level 为空时不按级别过滤，写入哪些级别完全由分发规则决定，写入出错时输出到标准错误。
+// 返回的 CloseFunc 应在进程退出前调用，将缓冲的日志写入磁盘。
+func NewWithConfig(cfg Config, opts ...LoggerOption) (*Logger, CloseFunc, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	d := cfg.Dispatch
+	if d == nil {
+		l := New(cfg, opts...)
+		return l, func() { _ = l.Sync() }, nil
+	}
+
+	rules := make([]ZapDispatch, 0, len(d.Rules))
+	for _, rule := range d.Rules {
+		levels := make([]zapcore.Level, len(rule.Levels))
+		for i, text := range rule.Levels {
+			_ = levels[i].UnmarshalText([]byte(text))
+		}
+		rules = append(rules, ZapDispatch{
+			FileSuffix:     rule.FileSuffix,
+			Levels:         levels,
+			EncoderBuilder: configEncoders[rule.Encoder],
+		})
+	}
+	writerOpts := []ZapWriterOptions{
+		WithOnErr(func(err error) {
+			fmt.Fprintln(os.Stderr, "logit:", err)
+		}),
+		WithMaxFileNum(d.MaxFileNum),
+		WithBufferSize(d.BufferSize),
+		WithFlushDuration(d.FlushInterval),
+		WithCheckDuration(d.CheckInterval),
+	}
+	l, closeFn, err := NewWithDispatch(d.RuleName, cfg.Filename, rules, nil, nil, writerOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Level != "" {
+		_ = l.SetLevelSpec(cfg.Level)
+	}
+	return l.WithLoggerOptions(opts...), closeFn, nil
+}
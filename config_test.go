@@ -0,0 +1,234 @@
+package logit
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		check   func(t *testing.T, cfg Config)
+		wantErr string
+	}{
+		{
+			name:    "lumberjack",
+			content: `{"filename":"app.log","max_size":100,"compress":true,"level":"info,db=debug"}`,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Filename != "app.log" || cfg.MaxSize != 100 || !cfg.Compress || cfg.Level != "info,db=debug" {
+					t.Errorf("cfg = %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "dispatch",
+			content: `{"filename":"app.log","dispatch":{"rule_name":"1hour","max_file_num":24,"flush_interval":"1s",
+				"rules":[{"levels":["debug","info"]},{"file_suffix":".wf","levels":["warn","error"],"encoder":"console"}]}}`,
+			check: func(t *testing.T, cfg Config) {
+				d := cfg.Dispatch
+				if d == nil || d.RuleName != "1hour" || d.MaxFileNum != 24 || d.FlushInterval != time.Second || len(d.Rules) != 2 {
+					t.Fatalf("dispatch = %+v", d)
+				}
+				if d.Rules[1].FileSuffix != ".wf" || d.Rules[1].Encoder != "console" {
+					t.Errorf("rule = %+v", d.Rules[1])
+				}
+			},
+		},
+		{
+			name:    "unknown key",
+			content: `{"file_name":"app.log"}`,
+			wantErr: `unknown field "file_name"`,
+		},
+		{
+			name:    "invalid level",
+			content: `{"level":"verbose"}`,
+			wantErr: "config level",
+		},
+		{
+			name:    "invalid duration",
+			content: `{"filename":"app.log","dispatch":{"flush_interval":"soon"}}`,
+			wantErr: "config dispatch.flush_interval",
+		},
+		{
+			name:    "invalid rule level",
+			content: `{"filename":"app.log","dispatch":{"rule_name":"1day","rules":[{"levels":["info"]},{"levels":["loud"]}]}}`,
+			wantErr: "config dispatch.rules[1].levels[0]",
+		},
+		{
+			name:    "missing rule name",
+			content: `{"filename":"app.log","dispatch":{"rules":[{"levels":["info"]}]}}`,
+			wantErr: "config dispatch.rule_name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(writeConfigFile(t, tt.content))
+			if err == nil {
+				err = cfg.Validate()
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadConfig() err = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() err = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("LOGIT_FILENAME", "env.log")
+	t.Setenv("LOGIT_MAX_AGE", "7")
+	t.Setenv("LOGIT_TO_STDOUT", "true")
+	t.Setenv("LOGIT_DISPATCH_RULE_NAME", "1day")
+	t.Setenv("LOGIT_DISPATCH_RULES", `[{"file_suffix":".wf","levels":["warn"]}]`)
+	t.Setenv("LOGIT_DISPATCH_CHECK_INTERVAL", "5s")
+
+	cfg, err := ConfigFromEnv("LOGIT_")
+	if err != nil {
+		t.Fatalf("ConfigFromEnv() err = %v", err)
+	}
+	if cfg.Filename != "env.log" || cfg.MaxAge != 7 || !cfg.ToStdout {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if d := cfg.Dispatch; d == nil || d.RuleName != "1day" || len(d.Rules) != 1 || d.CheckInterval != 5*time.Second {
+		t.Errorf("dispatch = %+v", d)
+	}
+
+	t.Setenv("LOGIT_MAX_SIZE", "big")
+	if _, err := ConfigFromEnv("LOGIT_"); err == nil || !strings.Contains(err.Error(), "LOGIT_MAX_SIZE") {
+		t.Errorf("ConfigFromEnv() err = %v, want naming LOGIT_MAX_SIZE", err)
+	}
+}
+
+func TestConfig_PartialLayers(t *testing.T) {
+	cfg, err := LoadConfig(writeConfigFile(t, `{"dispatch":{"rule_name":"1day"}}`))
+	if err != nil {
+		t.Fatalf("LoadConfig() err = %v, want partial config loaded without validation", err)
+	}
+	t.Setenv("LOGIT_FILENAME", filepath.Join(t.TempDir(), "app.log"))
+	t.Setenv("LOGIT_DISPATCH_RULES", `[{"levels":["info","error"]}]`)
+	if err := cfg.LoadEnv("LOGIT_"); err != nil {
+		t.Fatal(err)
+	}
+
+	logger, closeFn, err := NewWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewWithConfig() err = %v", err)
+	}
+	defer closeFn()
+	if d := cfg.Dispatch; d.RuleName != "1day" || len(d.Rules) != 1 || logger == nil {
+		t.Errorf("dispatch = %+v", d)
+	}
+}
+
+func TestConfig_Layering(t *testing.T) {
+	cfg, err := LoadConfig(writeConfigFile(t, `{"filename":"file.log","max_size":10,"level":"warn"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APP_LOG_MAX_SIZE", "20")
+	t.Setenv("APP_LOG_LEVEL", "info")
+	if err := cfg.LoadEnv("APP_LOG_"); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-log-level", "debug", "-log-compress", "-log-dispatch-max-file-num", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "config dispatch.rule_name") {
+		t.Errorf("Validate() err = %v, want dispatch.rule_name", err)
+	}
+
+	if cfg.Filename != "file.log" || cfg.MaxSize != 20 || cfg.Level != "debug" || !cfg.Compress {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if cfg.Dispatch == nil || cfg.Dispatch.MaxFileNum != 3 {
+		t.Errorf("dispatch = %+v", cfg.Dispatch)
+	}
+	if got := fs.Lookup("log-max-size").DefValue; got != "20" {
+		t.Errorf("log-max-size default = %s, want = 20", got)
+	}
+}
+
+func TestNewWithConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger, closeFn, err := NewWithConfig(Config{Filename: path, Level: "info,db=debug"})
+	if err != nil {
+		t.Fatalf("NewWithConfig() err = %v", err)
+	}
+	logger.Named("db").Debug(t.Context(), "db")
+	logger.Debug(t.Context(), "root")
+	closeFn()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"msg":"db"`) || strings.Contains(string(data), `"msg":"root"`) {
+		t.Errorf("log file = %s", data)
+	}
+
+	if _, _, err := NewWithConfig(Config{MaxAge: -1}); err == nil || !strings.Contains(err.Error(), "config max_age") {
+		t.Errorf("NewWithConfig() err = %v, want max_age", err)
+	}
+}
+
+func TestNewWithConfig_Dispatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	cfg := Config{
+		Filename: path,
+		Dispatch: &DispatchConfig{
+			RuleName: "no",
+			Rules: []DispatchRule{
+				{FileSuffix: "wf", Levels: []string{"warn", "error"}, Encoder: "console"},
+				{Levels: []string{"info"}},
+			},
+		},
+	}
+	logger, closeFn, err := NewWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewWithConfig() err = %v", err)
+	}
+	logger.Debug(t.Context(), "debug")
+	logger.Info(t.Context(), "info")
+	logger.Warn(t.Context(), "warn")
+	closeFn()
+
+	info, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 未指定编码器的规则使用 DefaultEncoder，不沿用上一条规则的 console 编码器
+	if !strings.HasPrefix(string(info), "{") || !strings.Contains(string(info), `"msg":"info"`) ||
+		strings.Contains(string(info), "debug") || strings.Contains(string(info), "warn") {
+		t.Errorf("info file = %s, want one json info line", info)
+	}
+	wf, err := os.ReadFile(path + ".wf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(string(wf), "{") || !strings.Contains(string(wf), "warn") || strings.Contains(string(wf), "info") {
+		t.Errorf("wf file = %s, want one console warn line", wf)
+	}
+}
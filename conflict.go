@@ -0,0 +1,286 @@
+package logit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ConflictPolicy 同名字段的冲突处理策略。
+// 写入时指同一类别内重复写入同名字段；输出时指元数据、提取器字段、普通字段、级别字段和调用方字段之间的同名字段，
+// 输出时按 元数据 → 提取器字段 → 普通字段 → 级别字段 → 调用方字段 的顺序合并，先出现的为已有值。
+// 级别字段包括 AddLevelFieldsAtLeast 写入的字段。
+type ConflictPolicy int
+
+const (
+	// ConflictDefault 写入时覆盖旧值且位置不变，输出时保留先出现的值（元数据优先），与历史行为一致
+	ConflictDefault ConflictPolicy = iota
+	// ConflictOverwrite 新值覆盖旧值，位置不变
+	ConflictOverwrite
+	// ConflictMoveToEnd 新值覆盖旧值，并移动到末尾
+	ConflictMoveToEnd
+	// ConflictKeepFirst 保留旧值，忽略新值
+	ConflictKeepFirst
+	// ConflictRename 保留旧值，新值以 "<key>_<n>" 重命名后保留
+	ConflictRename
+	// ConflictReport 按 ConflictDefault 处理并上报冲突，未设置 ConflictHandler 时使用 DefaultConflictHandler
+	ConflictReport
+)
+
+// ConflictHandler 冲突回调，err 为 *FieldConflictError。
+// 设置后无论采用哪种策略，检测到值不同的同名字段时都会调用。
+type ConflictHandler func(err error)
+
+// DefaultConflictHandler ConflictReport 策略下未设置 ConflictHandler 时使用的回调，默认输出到标准错误，应在初始化阶段设置
+var DefaultConflictHandler ConflictHandler = func(err error) {
+	fmt.Fprintln(os.Stderr, "logit:", err)
+}
+
+// FieldConflictError 同名字段冲突
+type FieldConflictError struct {
+	Key              string
+	Existing         zap.Field
+	Incoming         zap.Field
+	ExistingCategory FieldCategory
+	IncomingCategory FieldCategory
+}
+
+func (e *FieldConflictError) Error() string {
+	return fmt.Sprintf("field %q conflict: %s field overridden by %s field", e.Key, e.ExistingCategory, e.IncomingCategory)
+}
+
+// conflictConfig 冲突策略与回调
+type conflictConfig struct {
+	policy  ConflictPolicy
+	handler ConflictHandler
+}
+
+// or 未设置的部分使用 fallback 补全，上下文的设置优先于 Logger 的设置
+func (c conflictConfig) or(fallback conflictConfig) conflictConfig {
+	if c.policy == ConflictDefault {
+		c.policy = fallback.policy
+	}
+	if c.handler == nil {
+		c.handler = fallback.handler
+	}
+	return c
+}
+
+// report 上报冲突，未设置回调时只有 ConflictReport 策略使用 DefaultConflictHandler 上报
+func (c conflictConfig) report(conflicts []error) {
+	handler := c.handler
+	if handler == nil && c.policy == ConflictReport {
+		handler = DefaultConflictHandler
+	}
+	if handler == nil {
+		return
+	}
+	for _, err := range conflicts {
+		handler(err)
+	}
+}
+
+// WithConflictPolicy 设置 Logger 输出时的冲突策略，上下文通过 SetConflictPolicy 设置的策略优先
+func WithConflictPolicy(policy ConflictPolicy) LoggerOption {
+	return func(l *Logger) {
+		l.merge.conflict.policy = policy
+	}
+}
+
+// WithConflictHandler 设置 Logger 输出时的冲突回调，例如检测被元数据覆盖的调用方字段
+func WithConflictHandler(handler ConflictHandler) LoggerOption {
+	return func(l *Logger) {
+		l.merge.conflict.handler = handler
+	}
+}
+
+// SetConflictPolicy 设置上下文日志容器的冲突策略，同时作用于写入和输出
+func SetConflictPolicy(ctx context.Context, policy ConflictPolicy) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.conflict.policy = policy
+}
+
+// SetConflictHandler 设置上下文日志容器的冲突回调，同时作用于写入和输出
+func SetConflictHandler(ctx context.Context, handler ConflictHandler) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.conflict.handler = handler
+}
+
+// renameKey 返回第一个未被占用的 "<key>_<n>"
+func renameKey(key string, exists func(key string) bool) string {
+	for n := 1; ; n++ {
+		if k := key + "_" + strconv.Itoa(n); !exists(k) {
+			return k
+		}
+	}
+}
+
+// set 按容器的冲突策略写入字段，返回检测到的冲突，调用方需持有写锁并已调用 own
+func (b *LogBuffer) set(cat FieldCategory, lvl zapcore.Level, f zap.Field) error {
+	existing, ok := b.get(cat, lvl, f.Key)
+	if !ok || fieldEqual(existing, f) {
+		b.put(cat, lvl, f)
+		return nil
+	}
+
+	switch b.conflict.policy {
+	case ConflictKeepFirst:
+	case ConflictMoveToEnd:
+		b.delete(cat, lvl, f.Key)
+		b.put(cat, lvl, f)
+	case ConflictRename:
+		f = withKey(f, renameKey(f.Key, func(key string) bool {
+			_, ok := b.get(cat, lvl, key)
+			return ok
+		}))
+		b.put(cat, lvl, f)
+	default:
+		b.put(cat, lvl, f)
+	}
+	return &FieldConflictError{
+		Key:              existing.Key,
+		Existing:         existing,
+		Incoming:         f,
+		ExistingCategory: cat,
+		IncomingCategory: cat,
+	}
+}
+
+// setFields 加锁按冲突策略写入字段，释放锁后再上报冲突，避免回调中写日志时死锁
+func (b *LogBuffer) setFields(cat FieldCategory, lvl zapcore.Level, fields ...zap.Field) {
+	b.mu.Lock()
+	b.own()
+	var conflicts []error
+	for _, f := range fields {
+		if err := b.set(cat, lvl, f); err != nil {
+			conflicts = append(conflicts, err)
+		}
+	}
+	cc := b.conflict
+	b.mu.Unlock()
+
+	cc.report(conflicts)
+}
+
+// fieldMerger 按顺序合并多个来源的字段，并按冲突策略处理同名字段
+type fieldMerger struct {
+	policy    ConflictPolicy
+	fields    []zap.Field
+	sources   []FieldCategory
+	index     map[string]int
+	conflicts []error
+
+	// pinned 最前面的 pinned 个字段为元数据，pinnedChanged 表示其中有字段被覆盖或移动
+	pinned        int
+	pinnedChanged bool
+	// lazy 是否包含延迟计算的字段
+	lazy bool
+}
+
+// maxPooledFields 超过该字段数的 fieldMerger 不再放回对象池，避免长期占用大块内存
+const maxPooledFields = 1024
+
+var mergerPool = sync.Pool{
+	New: func() any {
+		return &fieldMerger{index: map[string]int{}}
+	},
+}
+
+// newFieldMerger 从对象池中取出 fieldMerger，使用完毕后调用 release 归还
+func newFieldMerger(policy ConflictPolicy) *fieldMerger {
+	m := mergerPool.Get().(*fieldMerger)
+	m.policy = policy
+	return m
+}
+
+// release 清空后归还对象池，之后不能再使用 m.fields，m 为 nil 时忽略
+func (m *fieldMerger) release() {
+	if m == nil || cap(m.fields) > maxPooledFields {
+		return
+	}
+	clear(m.fields)
+	m.fields = m.fields[:0]
+	m.sources = m.sources[:0]
+	clear(m.index)
+	m.conflicts = nil
+	m.pinned = 0
+	m.pinnedChanged = false
+	m.lazy = false
+	mergerPool.Put(m)
+}
+
+func (m *fieldMerger) append(cat FieldCategory, f zap.Field) {
+	if f.Key != "" {
+		m.index[f.Key] = len(m.fields)
+	}
+	m.fields = append(m.fields, f)
+	m.sources = append(m.sources, cat)
+	m.lazy = m.lazy || isLazy(f)
+}
+
+// resolve 计算合并结果中延迟计算的字段，需在确认日志级别开启、释放容器的锁之后调用
+func (m *fieldMerger) resolve() {
+	if m == nil || !m.lazy {
+		return
+	}
+	resolveLazy(m.fields)
+	m.lazy = false
+}
+
+// add 合并一个字段，值相同的同名字段直接忽略
+func (m *fieldMerger) add(cat FieldCategory, f zap.Field) {
+	i, ok := m.index[f.Key]
+	if f.Key == "" || !ok {
+		m.append(cat, f)
+		return
+	}
+	existing := m.fields[i]
+	if fieldEqual(existing, f) {
+		return
+	}
+	m.conflicts = append(m.conflicts, &FieldConflictError{
+		Key:              f.Key,
+		Existing:         existing,
+		Incoming:         f,
+		ExistingCategory: m.sources[i],
+		IncomingCategory: cat,
+	})
+
+	switch m.policy {
+	case ConflictOverwrite:
+		m.fields[i] = f
+		m.sources[i] = cat
+		m.lazy = m.lazy || isLazy(f)
+		m.pinnedChanged = m.pinnedChanged || i < m.pinned
+	case ConflictMoveToEnd:
+		// 原位置替换为空字段，避免移动切片
+		m.fields[i] = zap.Skip()
+		m.pinnedChanged = m.pinnedChanged || i < m.pinned
+		m.append(cat, f)
+	case ConflictRename:
+		f = withKey(f, renameKey(f.Key, func(key string) bool {
+			_, ok := m.index[key]
+			return ok
+		}))
+		m.append(cat, f)
+	}
+}
@@ -0,0 +1,170 @@
+package logit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestConflictPolicy_Output(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ConflictPolicy
+		want   string
+	}{
+		{
+			name:   "ConflictDefault",
+			policy: ConflictDefault,
+			want:   `"trace_id":"meta","uid":"10001"}`,
+		},
+		{
+			name:   "ConflictOverwrite",
+			policy: ConflictOverwrite,
+			want:   `"trace_id":"call","uid":"10001"}`,
+		},
+		{
+			name:   "ConflictMoveToEnd",
+			policy: ConflictMoveToEnd,
+			want:   `"uid":"10001","trace_id":"call"}`,
+		},
+		{
+			name:   "ConflictKeepFirst",
+			policy: ConflictKeepFirst,
+			want:   `"trace_id":"meta","uid":"10001"}`,
+		},
+		{
+			name:   "ConflictRename",
+			policy: ConflictRename,
+			want:   `"trace_id":"meta","uid":"10001","trace_id_1":"call"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported []error
+			logger, out := newTestLogger(zap.DebugLevel)
+			logger = logger.WithLoggerOptions(
+				WithConflictPolicy(tt.policy),
+				WithConflictHandler(func(err error) {
+					reported = append(reported, err)
+				}),
+			)
+			ctx := NewContext(context.Background())
+			AddMetaField(ctx, String("trace_id", "meta"))
+			AddField(ctx, String("uid", "10001"))
+
+			logger.Info(ctx, "conflict", String("trace_id", "call"))
+
+			if got := out.String(); !strings.Contains(got, tt.want) {
+				t.Errorf("Info() = %s, want contains %s", got, tt.want)
+			}
+			var conflict *FieldConflictError
+			if len(reported) != 1 || !errors.As(reported[0], &conflict) || conflict.Key != "trace_id" ||
+				conflict.ExistingCategory != CategoryMeta || conflict.IncomingCategory != CategoryCall {
+				t.Errorf("ConflictHandler() reported = %v, want one trace_id meta/call conflict", reported)
+			}
+		})
+	}
+}
+
+func TestConflictPolicy_Write(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ConflictPolicy
+		want   string
+	}{
+		{
+			name:   "ConflictOverwrite",
+			policy: ConflictOverwrite,
+			want:   `"uid":"2","step":"a"}`,
+		},
+		{
+			name:   "ConflictMoveToEnd",
+			policy: ConflictMoveToEnd,
+			want:   `"step":"a","uid":"2"}`,
+		},
+		{
+			name:   "ConflictKeepFirst",
+			policy: ConflictKeepFirst,
+			want:   `"uid":"1","step":"a"}`,
+		},
+		{
+			name:   "ConflictRename",
+			policy: ConflictRename,
+			want:   `"uid":"1","step":"a","uid_1":"2"}`,
+		},
+		{
+			name:   "ConflictReport",
+			policy: ConflictReport,
+			want:   `"uid":"2","step":"a"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported int
+			logger, out := newTestLogger(zap.DebugLevel)
+			ctx := NewContext(context.Background())
+			SetConflictPolicy(ctx, tt.policy)
+			SetConflictHandler(ctx, func(err error) {
+				reported++
+			})
+
+			AddField(ctx, String("uid", "1"))
+			AddField(ctx, String("step", "a"))
+			AddField(ctx, String("uid", "1"))
+			AddField(ctx, String("uid", "2"))
+			logger.Info(ctx, "conflict")
+
+			if got := out.String(); !strings.Contains(got, tt.want) {
+				t.Errorf("Info() = %s, want contains %s", got, tt.want)
+			}
+			if reported != 1 {
+				t.Errorf("ConflictHandler() reported = %d, want = 1", reported)
+			}
+		})
+	}
+}
+
+func TestConflictPolicy_Report(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ConflictPolicy
+		want   int
+	}{
+		{
+			name:   "ConflictDefault",
+			policy: ConflictDefault,
+		},
+		{
+			name:   "ConflictReport",
+			policy: ConflictReport,
+			want:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported int
+			prev := DefaultConflictHandler
+			DefaultConflictHandler = func(err error) {
+				reported++
+			}
+			t.Cleanup(func() {
+				DefaultConflictHandler = prev
+			})
+
+			logger, _ := newTestLogger(zap.DebugLevel)
+			ctx := NewContext(context.Background())
+			SetConflictPolicy(ctx, tt.policy)
+			AddField(ctx, String("uid", "1"))
+			AddField(ctx, String("uid", "2"))
+			AddMetaField(ctx, String("trace_id", "meta"))
+			logger.Info(ctx, "conflict", String("trace_id", "call"))
+
+			if reported != tt.want {
+				t.Errorf("DefaultConflictHandler() reported = %d, want = %d", reported, tt.want)
+			}
+		})
+	}
+}
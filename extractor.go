@@ -0,0 +1,51 @@
+package logit
+
+import (
+	"context"
+	"slices"
+
+	"go.uber.org/zap"
+)
+
+// Extractor 从 context 中提取日志字段，例如其他组件保存的鉴权信息、租户 ID 或链路追踪的 span
+type Extractor func(ctx context.Context) []zap.Field
+
+// WithExtractor 注册上下文字段提取器，每条日志输出时按注册顺序执行，
+// 提取的字段位于元数据字段之后、普通字段之前，与日志容器中的同名字段按冲突策略处理。
+// 上下文中没有日志容器时同样生效。
+func WithExtractor(fn Extractor) LoggerOption {
+	return func(l *Logger) {
+		if fn != nil {
+			// Clip 保证不同 Logger 副本之间不共享底层数组
+			l.merge.extractors = append(slices.Clip(l.merge.extractors), fn)
+		}
+	}
+}
+
+// extract 依次执行提取器，在获取日志容器的锁之前调用，提取器内可以安全地读写日志容器
+func (mo mergeOptions) extract(ctx context.Context) []zap.Field {
+	if len(mo.extractors) == 0 {
+		return nil
+	}
+	var fields []zap.Field
+	for _, fn := range mo.extractors {
+		fields = append(fields, fn(ctx)...)
+	}
+	return fields
+}
+
+// mergeExtracted 上下文中没有日志容器时只合并提取的字段和调用方字段
+func mergeExtracted(mo mergeOptions, extracted, fields []zap.Field) ([]zap.Field, *fieldMerger) {
+	if len(extracted) == 0 {
+		return fields, nil
+	}
+	m := newFieldMerger(mo.conflict.policy)
+	for _, f := range extracted {
+		m.add(CategoryExtracted, f)
+	}
+	for _, f := range fields {
+		m.add(CategoryCall, f)
+	}
+	mo.conflict.report(m.conflicts)
+	return m.fields, m
+}
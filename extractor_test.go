@@ -0,0 +1,117 @@
+package logit
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+type tenantKey struct{}
+
+func tenantExtractor(ctx context.Context) []zap.Field {
+	if v, ok := ctx.Value(tenantKey{}).(string); ok {
+		return []zap.Field{String("tenant", v)}
+	}
+	return nil
+}
+
+func TestWithExtractor(t *testing.T) {
+	tests := []struct {
+		name    string
+		buffer  bool
+		prepare func(ctx context.Context)
+		order   []string
+		want    map[string]any
+	}{
+		{
+			name:   "after meta",
+			buffer: true,
+			prepare: func(ctx context.Context) {
+				AddMetaField(ctx, String("trace_id", "abc"))
+				AddField(ctx, String("uid", "10001"))
+			},
+			order: []string{`"trace_id"`, `"tenant"`, `"span"`, `"uid"`},
+			want:  map[string]any{"tenant": "t1", "span": "s1"},
+		},
+		{
+			name:    "meta wins",
+			buffer:  true,
+			prepare: func(ctx context.Context) { AddMetaField(ctx, String("tenant", "meta")) },
+			want:    map[string]any{"tenant": "meta", "span": "s1"},
+		},
+		{
+			name:    "extracted wins over normal",
+			buffer:  true,
+			prepare: func(ctx context.Context) { AddField(ctx, String("tenant", "normal")) },
+			want:    map[string]any{"tenant": "t1"},
+		},
+		{
+			name:  "without buffer",
+			order: []string{`"tenant"`, `"span"`, `"call"`},
+			want:  map[string]any{"tenant": "t1", "span": "s1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.InfoLevel)
+			logger = logger.WithLoggerOptions(
+				WithExtractor(tenantExtractor),
+				WithExtractor(func(context.Context) []zap.Field { return []zap.Field{String("span", "s1")} }),
+			)
+			ctx := context.WithValue(context.Background(), tenantKey{}, "t1")
+			if tt.buffer {
+				ctx = NewContext(ctx)
+				tt.prepare(ctx)
+			}
+			logger.Info(ctx, "extract", String("call", "1"))
+
+			raw := out.String()
+			for i := 1; i < len(tt.order); i++ {
+				if strings.Index(raw, tt.order[i-1]) > strings.Index(raw, tt.order[i]) {
+					t.Errorf("Info() %s should be before %s: %s", tt.order[i-1], tt.order[i], raw)
+				}
+			}
+			line := decodeLines(t, out)[0]
+			for k, v := range tt.want {
+				if line[k] != v {
+					t.Errorf("Info() %s = %v, want = %v", k, line[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestWithExtractor_Isolation(t *testing.T) {
+	base, out := newTestLogger(zap.InfoLevel)
+	base = base.WithLoggerOptions(WithExtractor(tenantExtractor))
+	a := base.WithLoggerOptions(WithExtractor(func(context.Context) []zap.Field { return []zap.Field{String("a", "1")} }))
+	b := base.WithLoggerOptions(WithExtractor(func(context.Context) []zap.Field { return []zap.Field{String("b", "1")} }))
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "t1")
+	a.Info(ctx, "a")
+	b.Info(ctx, "b")
+	lines := decodeLines(t, out)
+	if _, ok := lines[0]["b"]; ok {
+		t.Errorf("logger a has extractor of b: %v", lines[0])
+	}
+	if _, ok := lines[1]["a"]; ok {
+		t.Errorf("logger b has extractor of a: %v", lines[1])
+	}
+}
+
+func TestZapHandler_Extractor(t *testing.T) {
+	logger, out := newTestLogger(zap.InfoLevel)
+	handler := NewZapHandler(logger, WithExtractor(tenantExtractor))
+
+	ctx := NewContext(context.WithValue(context.Background(), tenantKey{}, "t1"))
+	AddMetaField(ctx, String("trace_id", "abc"))
+	slog.New(handler).InfoContext(ctx, "slog")
+
+	raw := out.String()
+	if !strings.Contains(raw, `"tenant":"t1"`) || strings.Index(raw, `"trace_id"`) > strings.Index(raw, `"tenant"`) {
+		t.Errorf("ZapHandler output = %s, want trace_id before tenant", raw)
+	}
+}
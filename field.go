@@ -1,6 +1,11 @@
 package logit
 
-import "go.uber.org/zap"
+import (
+	"reflect"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
 
 var (
 	Binary     = zap.Binary
@@ -44,3 +49,34 @@ var (
 	Error      = zap.Error
 	Any        = zap.Any
 )
+
+// fieldValue 解析 zap.Field 中保存的值
+func fieldValue(f zap.Field) any {
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	return enc.Fields[f.Key]
+}
+
+// anyArray 将任意值序列化为数组
+type anyArray []any
+
+func (a anyArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, v := range a {
+		if err := enc.AppendReflected(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldEqual 比较两个字段是否相同。zap.Field.Equals 对内联对象和 Stringer 直接使用 == 比较，
+// 值中包含切片等不可比较的类型时会 panic，这两类字段改用 reflect.DeepEqual 比较
+func fieldEqual(a, b zap.Field) bool {
+	switch a.Type {
+	case zapcore.InlineMarshalerType, zapcore.StringerType:
+		return a.Type == b.Type && a.Key == b.Key && a.Integer == b.Integer && a.String == b.String &&
+			reflect.DeepEqual(a.Interface, b.Interface)
+	default:
+		return a.Equals(b)
+	}
+}
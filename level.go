@@ -0,0 +1,518 @@
+package logit
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// errLevelFixed 直接构造、未经 New 等函数创建的 Logger 没有可调整的级别
+var errLevelFixed = errors.New("logit: level is not adjustable, create the Logger with New, NewWithZap or NewWithDispatch")
+
+// Level 返回当前的默认日志级别，不包括按名称设置的级别。
+// 直接构造的 Logger（例如 &Logger{Logger: z}）没有可调整的级别，返回 core 自身的级别
+func (l *Logger) Level() zapcore.Level {
+	if l.level == nil {
+		return zapcore.LevelOf(l.Core())
+	}
+	return l.level.base.Level()
+}
+
+// SetLevel 运行时调整默认日志级别，按名称设置的级别不受影响，对共享同一 Logger 的副本同时生效。
+// 通过 NewWithZap 包装的 logger 不能低于原 core 自身的级别，直接构造的 Logger 不做任何调整。
+func (l *Logger) SetLevel(lvl zapcore.Level) {
+	if l.level == nil {
+		return
+	}
+	l.level.base.SetLevel(lvl)
+}
+
+// LevelSpec 返回当前的级别配置，格式与 SetLevelSpec 相同
+func (l *Logger) LevelSpec() string {
+	if l.level == nil {
+		return LevelSpec{Level: l.Level()}.String()
+	}
+	return l.level.spec().String()
+}
+
+// SetLevelSpec 运行时调整默认级别和按名称设置的级别，格式见 ParseLevelSpec，
+// 解析失败时返回错误且不做任何调整，对共享同一 Logger 的副本以及 Named 派生的 Logger 同时生效。
+// 直接构造的 Logger 没有可调整的级别，返回错误
+func (l *Logger) SetLevelSpec(spec string) error {
+	parsed, err := ParseLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+	if l.level == nil {
+		return errLevelFixed
+	}
+	l.level.set(parsed)
+	return nil
+}
+
+// Named 返回指定名称的子 Logger，名称以 "." 与已有名称拼接，例如 logger.Named("http").Named("client") 的名称为 http.client。
+// 子 Logger 与原 Logger 共享日志级别，按名称设置的级别按层级匹配，见 ParseLevelSpec。
+// 同一名称的子 Logger 复用同一个 zap.Logger，每次请求调用 Named 时上下文的元数据缓存依然有效。
+func (l *Logger) Named(name string) *Logger {
+	c := *l
+	c.Logger, c.named = l.named.get(l.Logger, name)
+	return &c
+}
+
+// maxNamedLoggers 每个 Logger 最多缓存的子 Logger 数量，超出后新的名称不再缓存，避免动态名称导致无限增长
+const maxNamedLoggers = 64
+
+// namedLoggers 缓存 Named 派生的子 zap.Logger，由 Logger 及其副本共享
+type namedLoggers struct {
+	mu       sync.Mutex
+	children map[string]namedLogger
+}
+
+type namedLogger struct {
+	logger *zap.Logger
+	named  *namedLoggers
+}
+
+// get 返回 parent 指定名称的子 logger 以及子 logger 的缓存，n 为 nil 时（直接构造的 Logger）不缓存
+func (n *namedLoggers) get(parent *zap.Logger, name string) (*zap.Logger, *namedLoggers) {
+	if n == nil {
+		return parent.Named(name), nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if c, ok := n.children[name]; ok {
+		return c.logger, c.named
+	}
+	c := namedLogger{logger: parent.Named(name), named: &namedLoggers{}}
+	if len(n.children) < maxNamedLoggers {
+		if n.children == nil {
+			n.children = map[string]namedLogger{}
+		}
+		n.children[name] = c
+	}
+	return c.logger, c.named
+}
+
+// enabled 判断当前名称下指定级别的日志是否输出
+func (l *Logger) enabled(lvl zapcore.Level) bool {
+	return lvl >= l.level.levelFor(l.Name()) && l.Core().Enabled(lvl)
+}
+
+// LevelSpec 日志级别配置，默认级别之外可以按 logger 名称单独设置级别
+type LevelSpec struct {
+	// Level 默认级别
+	Level zapcore.Level
+	// Names 按名称设置的级别，名称按 "." 分层，db 同时匹配 db 和 db.conn，
+	// 同时匹配多个名称时以最长的名称为准
+	Names map[string]zapcore.Level
+}
+
+// ParseLevelSpec 解析形如 "info,db=debug,http.client=warn" 的级别配置：
+// 不带名称的一项为默认级别，缺省为 info；带名称的项为该名称及其子名称的级别。
+// 解析失败时返回已解析的部分以及第一个错误。
+func ParseLevelSpec(spec string) (LevelSpec, error) {
+	parsed := LevelSpec{Level: zap.InfoLevel}
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, text, named := strings.Cut(item, "=")
+		if !named {
+			name, text = "", item
+		}
+		name = strings.TrimSpace(name)
+		var lvl zapcore.Level
+		if err := lvl.UnmarshalText([]byte(strings.TrimSpace(text))); err != nil {
+			fail(fmt.Errorf("level spec %q: %w", item, err))
+			continue
+		}
+		switch {
+		case !named:
+			parsed.Level = lvl
+		case name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "."):
+			fail(fmt.Errorf("level spec %q: invalid logger name", item))
+		default:
+			if parsed.Names == nil {
+				parsed.Names = map[string]zapcore.Level{}
+			}
+			parsed.Names[name] = lvl
+		}
+	}
+	return parsed, firstErr
+}
+
+// String 按 ParseLevelSpec 的格式输出，名称按字典序排列
+func (s LevelSpec) String() string {
+	items := []string{s.Level.String()}
+	for _, name := range slices.Sorted(maps.Keys(s.Names)) {
+		items = append(items, name+"="+s.Names[name].String())
+	}
+	return strings.Join(items, ",")
+}
+
+// levelControl 可运行时调整的日志级别，由 Logger 及其副本、Named 派生的 Logger 共享
+type levelControl struct {
+	base  zap.AtomicLevel
+	names atomic.Pointer[nameLevels]
+}
+
+// nameLevels 按名称设置的级别，list 按名称从长到短排列，保证优先匹配最具体的名称
+type nameLevels struct {
+	list []nameLevel
+	// min 各名称级别中的最低级别
+	min zapcore.Level
+}
+
+type nameLevel struct {
+	name  string
+	level zapcore.Level
+}
+
+func newLevelControl(spec LevelSpec) *levelControl {
+	c := &levelControl{base: zap.NewAtomicLevelAt(spec.Level)}
+	c.set(spec)
+	return c
+}
+
+func (c *levelControl) set(spec LevelSpec) {
+	if len(spec.Names) == 0 {
+		c.names.Store(nil)
+	} else {
+		n := &nameLevels{min: zapcore.InvalidLevel}
+		for name, lvl := range spec.Names {
+			n.list = append(n.list, nameLevel{name: name, level: lvl})
+			n.min = min(n.min, lvl)
+		}
+		slices.SortFunc(n.list, func(a, b nameLevel) int {
+			return cmp.Or(cmp.Compare(len(b.name), len(a.name)), strings.Compare(a.name, b.name))
+		})
+		c.names.Store(n)
+	}
+	c.base.SetLevel(spec.Level)
+}
+
+func (c *levelControl) spec() LevelSpec {
+	spec := LevelSpec{Level: c.base.Level()}
+	if n := c.names.Load(); n != nil {
+		spec.Names = make(map[string]zapcore.Level, len(n.list))
+		for _, nl := range n.list {
+			spec.Names[nl.name] = nl.level
+		}
+	}
+	return spec
+}
+
+// levelFor 返回指定名称的日志级别，c 为 nil 时不做限制
+func (c *levelControl) levelFor(name string) zapcore.Level {
+	if c == nil {
+		return zapcore.DebugLevel
+	}
+	if n := c.names.Load(); n != nil && name != "" {
+		for _, nl := range n.list {
+			if name == nl.name || strings.HasPrefix(name, nl.name) && name[len(nl.name)] == '.' {
+				return nl.level
+			}
+		}
+	}
+	return c.base.Level()
+}
+
+// Enabled 任意名称下开启的级别，作为 core 的 LevelEnabler 使用
+func (c *levelControl) Enabled(lvl zapcore.Level) bool {
+	return lvl >= c.Level()
+}
+
+// Level 默认级别与各名称级别中的最低级别
+func (c *levelControl) Level() zapcore.Level {
+	lvl := c.base.Level()
+	if n := c.names.Load(); n != nil {
+		lvl = min(lvl, n.min)
+	}
+	return lvl
+}
+
+// levelCore 按可调整的日志级别过滤的 core，Check 时按日志的 logger 名称匹配级别
+type levelCore struct {
+	zapcore.Core
+	level *levelControl
+}
+
+// withLevel 返回由 level 控制日志级别的 logger
+func withLevel(logger *zap.Logger, level *levelControl) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, level: level}
+	}))
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl) && c.Core.Enabled(lvl)
+}
+
+// Level 返回可调整级别与原 core 级别中的较高者，供 zapcore.LevelOf 使用
+func (c *levelCore) Level() zapcore.Level {
+	return max(c.level.Level(), zapcore.LevelOf(c.Core))
+}
+
+func (c *levelCore) With(fields []zap.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.level.levelFor(ent.LoggerName) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// bypassLevel 返回跳过可调整级别过滤的 logger，用于 WithLevel 设置了更低级别的请求
+func bypassLevel(logger *zap.Logger) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if c, ok := core.(*levelCore); ok {
+			return c.Core
+		}
+		return core
+	}))
+}
+
+// LevelHandlerOption LevelHandler 的可选参数
+type LevelHandlerOption func(*levelHandler)
+
+// WithLevelRevert 设置默认的自动恢复时间，调整级别 d 之后恢复为调整前的级别，请求中指定了 duration 时以请求为准
+func WithLevelRevert(d time.Duration) LevelHandlerOption {
+	return func(h *levelHandler) {
+		h.revertAfter = d
+	}
+}
+
+// WithMaxLevelRevert 设置自动恢复时间的上限，请求中的 duration 为 0 或超出上限时按上限处理，
+// 避免线上长期停留在 Debug 级别
+func WithMaxLevelRevert(d time.Duration) LevelHandlerOption {
+	return func(h *levelHandler) {
+		h.maxRevert = d
+	}
+}
+
+// levelHandler 运行时调整日志级别的 HTTP 接口
+type levelHandler struct {
+	logger      *Logger
+	revertAfter time.Duration
+	maxRevert   time.Duration
+
+	mu sync.Mutex
+	// timer 等待中的自动恢复，base 为恢复的目标级别
+	timer    *time.Timer
+	base     zapcore.Level
+	revertAt time.Time
+}
+
+// levelPayload 请求和响应的 JSON 结构
+type levelPayload struct {
+	Level    string `json:"level"`
+	Duration string `json:"duration,omitempty"`
+	RevertAt string `json:"revert_at,omitempty"`
+}
+
+// LevelHandler 返回运行时调整日志级别的 HTTP 接口：
+//
+//	GET  返回当前级别，例如 {"level":"info"}，等待自动恢复时附带 revert_at
+//	PUT  调整级别，支持 JSON {"level":"debug","duration":"10m"} 或表单 level=debug&duration=10m
+//
+// duration 为自动恢复时间，到期后恢复为调整前的级别，未指定时使用 WithLevelRevert 设置的默认值，
+// 为 0 时不自动恢复。等待恢复期间再次调整时，恢复的目标仍为第一次调整前的级别。
+func (l *Logger) LevelHandler(opts ...LevelHandlerOption) http.Handler {
+	h := &levelHandler{logger: l}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		lvl, d, err := h.decode(r)
+		if err != nil {
+			writeLevelJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		h.set(lvl, d)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeLevelJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "only GET and PUT are supported"})
+		return
+	}
+	writeLevelJSON(w, http.StatusOK, h.state())
+}
+
+// decode 解析请求中的级别和自动恢复时间
+func (h *levelHandler) decode(r *http.Request) (zapcore.Level, time.Duration, error) {
+	var p levelPayload
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			return 0, 0, fmt.Errorf("decode request: %w", err)
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return 0, 0, fmt.Errorf("parse form: %w", err)
+		}
+		p.Level = r.Form.Get("level")
+		p.Duration = r.Form.Get("duration")
+	}
+
+	if p.Level == "" {
+		return 0, 0, errors.New("must specify a logging level")
+	}
+	var lvl zapcore.Level
+	if err := lvl.UnmarshalText([]byte(p.Level)); err != nil {
+		return 0, 0, err
+	}
+
+	d := h.revertAfter
+	if p.Duration != "" {
+		var err error
+		if d, err = time.ParseDuration(p.Duration); err != nil {
+			return 0, 0, fmt.Errorf("invalid duration %q: %w", p.Duration, err)
+		}
+		if d < 0 {
+			return 0, 0, fmt.Errorf("invalid duration %q: must not be negative", p.Duration)
+		}
+	}
+	if h.maxRevert > 0 && (d == 0 || d > h.maxRevert) {
+		d = h.maxRevert
+	}
+	return lvl, d, nil
+}
+
+// set 调整级别，d 大于 0 时安排自动恢复
+func (h *levelHandler) set(lvl zapcore.Level, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.timer == nil {
+		h.base = h.logger.Level()
+	} else {
+		h.timer.Stop()
+		h.timer = nil
+		h.revertAt = time.Time{}
+	}
+	h.logger.SetLevel(lvl)
+	if d <= 0 {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		// 已被之后的调整取代
+		if h.timer != timer {
+			return
+		}
+		h.logger.SetLevel(h.base)
+		h.timer = nil
+		h.revertAt = time.Time{}
+	})
+	h.timer = timer
+	h.revertAt = time.Now().Add(d)
+}
+
+func (h *levelHandler) state() levelPayload {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p := levelPayload{Level: h.logger.Level().String()}
+	if h.timer != nil {
+		p.RevertAt = h.revertAt.Format(time.RFC3339)
+	}
+	return p
+}
+
+func writeLevelJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// LevelOverrideHeader LevelMiddleware 读取的请求头，值由 SignLevel 生成
+const LevelOverrideHeader = "X-Log-Level"
+
+// SignLevel 使用 HMAC-SHA256 生成 LevelMiddleware 校验的请求头值，格式为 "级别:过期时间戳:签名"，
+// 例如 debug:1735689600:5d41...，过期后请求头不再生效
+func SignLevel(secret []byte, lvl zapcore.Level, expires time.Time) string {
+	payload := lvl.String() + ":" + strconv.FormatInt(expires.Unix(), 10)
+	return payload + ":" + hex.EncodeToString(levelSignature(secret, payload))
+}
+
+// LevelMiddleware 返回 HTTP 中间件，请求头 X-Log-Level 的签名校验通过且未过期时，
+// 通过 WithLevel 为该请求开启请求头中的日志级别。请求头缺失或无效时不做任何处理，请求照常执行。
+// secret 为空时中间件不生效，避免接受未签名的请求头。
+func LevelMiddleware(secret []byte) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if lvl, ok := verifyLevel(secret, r.Header.Get(LevelOverrideHeader), time.Now()); ok {
+				r = r.WithContext(WithLevel(r.Context(), lvl))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// verifyLevel 校验 SignLevel 生成的请求头值，返回其中的日志级别
+func verifyLevel(secret []byte, value string, now time.Time) (zapcore.Level, bool) {
+	if len(secret) == 0 || value == "" {
+		return 0, false
+	}
+	i := strings.LastIndexByte(value, ':')
+	if i < 0 {
+		return 0, false
+	}
+	payload, sig := value[:i], value[i+1:]
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, levelSignature(secret, payload)) {
+		return 0, false
+	}
+
+	text, exp, ok := strings.Cut(payload, ":")
+	if !ok {
+		return 0, false
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return 0, false
+	}
+	var lvl zapcore.Level
+	if err := lvl.UnmarshalText([]byte(text)); err != nil {
+		return 0, false
+	}
+	return lvl, true
+}
+
+func levelSignature(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
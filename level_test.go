@@ -0,0 +1,394 @@
+package logit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogger_SetLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    func() (*Logger, *bytes.Buffer)
+		set      zapcore.Level
+		wantMsgs int
+	}{
+		{
+			name: "raise",
+			build: func() (*Logger, *bytes.Buffer) {
+				return newTestLogger(zapcore.DebugLevel)
+			},
+			set:      zap.WarnLevel,
+			wantMsgs: 2,
+		},
+		{
+			name: "lower to core level",
+			build: func() (*Logger, *bytes.Buffer) {
+				l, out := newTestLogger(zapcore.DebugLevel)
+				l.SetLevel(zap.ErrorLevel)
+				return l, out
+			},
+			set:      zap.DebugLevel,
+			wantMsgs: 4,
+		},
+		{
+			name: "dispatch",
+			build: func() (*Logger, *bytes.Buffer) {
+				out := &bytes.Buffer{}
+				core := zapcore.NewCore(getEncoder(), zapcore.AddSync(out),
+					newLevelFilter([]zapcore.Level{zap.DebugLevel, zap.InfoLevel, zap.WarnLevel, zap.ErrorLevel}))
+				level := newLevelControl(LevelSpec{Level: zap.DebugLevel})
+				return &Logger{Logger: withLevel(zap.New(core), level), level: level}, out
+			},
+			set:      zap.InfoLevel,
+			wantMsgs: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := tt.build()
+			logger.SetLevel(tt.set)
+			if got := logger.Level(); got != tt.set {
+				t.Errorf("Level() = %v, want = %v", got, tt.set)
+			}
+			ctx := context.Background()
+			logger.Debug(ctx, "debug")
+			logger.Info(ctx, "info")
+			logger.Warn(ctx, "warn")
+			logger.Error(ctx, "error")
+			if got := len(decodeLines(t, out)); got != tt.wantMsgs {
+				t.Errorf("lines = %d, want = %d", got, tt.wantMsgs)
+			}
+		})
+	}
+}
+
+func TestLogger_SetLevelBelowCore(t *testing.T) {
+	logger, out := newTestLogger(zapcore.InfoLevel)
+	logger.SetLevel(zap.DebugLevel)
+	logger.Debug(context.Background(), "debug")
+	if out.Len() != 0 {
+		t.Errorf("Debug() wrote %s below core level", out.String())
+	}
+}
+
+func serveLevel(h http.Handler, method, contentType, body string) (*httptest.ResponseRecorder, map[string]string) {
+	req := httptest.NewRequest(method, "/log/level", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	resp := map[string]string{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp
+}
+
+func TestLogger_LevelUnmanaged(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := &Logger{Logger: zap.New(zapcore.NewCore(getEncoder(), zapcore.AddSync(out), zap.InfoLevel))}
+
+	logger.SetLevel(zap.DebugLevel)
+	if got := logger.Level(); got != zap.InfoLevel {
+		t.Errorf("Level() = %v, want = info", got)
+	}
+	if got := logger.LevelSpec(); got != "info" {
+		t.Errorf("LevelSpec() = %q, want = info", got)
+	}
+	if err := logger.SetLevelSpec("debug"); err == nil {
+		t.Errorf("SetLevelSpec() err = nil, want error for a Logger without adjustable level")
+	}
+	logger.Debug(context.Background(), "debug")
+	logger.Info(context.Background(), "info")
+	if got := strings.Count(out.String(), "\n"); got != 1 {
+		t.Errorf("lines = %d, want = 1: %s", got, out.String())
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	form := "application/x-www-form-urlencoded"
+	tests := []struct {
+		name        string
+		opts        []LevelHandlerOption
+		method      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantLevel   zapcore.Level
+		wantRevert  bool
+	}{
+		{
+			name:       "get",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantLevel:  zap.InfoLevel,
+		},
+		{
+			name:        "put json",
+			method:      http.MethodPut,
+			contentType: "application/json",
+			body:        `{"level":"debug"}`,
+			wantStatus:  http.StatusOK,
+			wantLevel:   zap.DebugLevel,
+		},
+		{
+			name:        "put form with duration",
+			method:      http.MethodPut,
+			contentType: form,
+			body:        url.Values{"level": {"warn"}, "duration": {"1h"}}.Encode(),
+			wantStatus:  http.StatusOK,
+			wantLevel:   zap.WarnLevel,
+			wantRevert:  true,
+		},
+		{
+			name:        "default revert",
+			opts:        []LevelHandlerOption{WithLevelRevert(time.Hour)},
+			method:      http.MethodPut,
+			contentType: form,
+			body:        "level=debug",
+			wantStatus:  http.StatusOK,
+			wantLevel:   zap.DebugLevel,
+			wantRevert:  true,
+		},
+		{
+			name:        "max revert",
+			opts:        []LevelHandlerOption{WithMaxLevelRevert(time.Hour)},
+			method:      http.MethodPut,
+			contentType: "application/json",
+			body:        `{"level":"debug","duration":"0s"}`,
+			wantStatus:  http.StatusOK,
+			wantLevel:   zap.DebugLevel,
+			wantRevert:  true,
+		},
+		{
+			name:        "bad level",
+			method:      http.MethodPut,
+			contentType: form,
+			body:        "level=verbose",
+			wantStatus:  http.StatusBadRequest,
+			wantLevel:   zap.InfoLevel,
+		},
+		{
+			name:        "bad duration",
+			method:      http.MethodPut,
+			contentType: form,
+			body:        "level=debug&duration=soon",
+			wantStatus:  http.StatusBadRequest,
+			wantLevel:   zap.InfoLevel,
+		},
+		{
+			name:       "method",
+			method:     http.MethodDelete,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantLevel:  zap.InfoLevel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, _ := newTestLogger(zapcore.DebugLevel)
+			logger.SetLevel(zap.InfoLevel)
+			h := logger.LevelHandler(tt.opts...)
+
+			rec, resp := serveLevel(h, tt.method, tt.contentType, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want = %d, body = %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := logger.Level(); got != tt.wantLevel {
+				t.Errorf("Level() = %v, want = %v", got, tt.wantLevel)
+			}
+			if rec.Code == http.StatusOK && resp["level"] != tt.wantLevel.String() {
+				t.Errorf("response level = %s, want = %v", resp["level"], tt.wantLevel)
+			}
+			if _, ok := resp["revert_at"]; ok != tt.wantRevert {
+				t.Errorf("response revert_at = %q, want = %v", resp["revert_at"], tt.wantRevert)
+			}
+		})
+	}
+}
+
+func TestLevelHandler_Revert(t *testing.T) {
+	logger, _ := newTestLogger(zapcore.DebugLevel)
+	logger.SetLevel(zap.WarnLevel)
+	h := logger.LevelHandler()
+
+	serveLevel(h, http.MethodPut, "application/json", `{"level":"info","duration":"1h"}`)
+	serveLevel(h, http.MethodPut, "application/json", `{"level":"debug","duration":"20ms"}`)
+	if got := logger.Level(); got != zap.DebugLevel {
+		t.Fatalf("Level() = %v, want = debug", got)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for logger.Level() != zap.WarnLevel && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := logger.Level(); got != zap.WarnLevel {
+		t.Errorf("Level() after revert = %v, want = warn", got)
+	}
+	if _, resp := serveLevel(h, http.MethodGet, "", ""); resp["revert_at"] != "" {
+		t.Errorf("revert_at = %q after revert", resp["revert_at"])
+	}
+}
+
+func TestParseLevelSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "", want: "info"},
+		{spec: "debug", want: "debug"},
+		{spec: "info, db=debug ,http.client=warn", want: "info,db=debug,http.client=warn"},
+		{spec: "db=debug", want: "info,db=debug"},
+		{spec: "verbose,db=debug", want: "info,db=debug", wantErr: true},
+		{spec: "warn,db=loud", want: "warn", wantErr: true},
+		{spec: "warn,.db=debug", want: "warn", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseLevelSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLevelSpec() err = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseLevelSpec() = %s, want = %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogger_Named(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  string
+		names []string
+		want  []string
+	}{
+		{
+			name:  "default",
+			spec:  "info",
+			names: []string{"", "db", "http.client"},
+			want:  []string{"info", "warn", "db/info", "db/warn", "http.client/info", "http.client/warn"},
+		},
+		{
+			name:  "hierarchical",
+			spec:  "warn,db=debug,http=info,http.client=error",
+			names: []string{"", "db", "db.conn", "dbx", "http", "http.server", "http.client", "http.client.pool"},
+			want: []string{
+				"warn",
+				"db/debug", "db/info", "db/warn",
+				"db.conn/debug", "db.conn/info", "db.conn/warn",
+				"dbx/warn",
+				"http/info", "http/warn",
+				"http.server/info", "http.server/warn",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zapcore.DebugLevel)
+			if err := logger.SetLevelSpec(tt.spec); err != nil {
+				t.Fatalf("SetLevelSpec() err = %v", err)
+			}
+			ctx := context.Background()
+			for _, name := range tt.names {
+				l := logger
+				for _, part := range strings.Split(name, ".") {
+					if part != "" {
+						l = l.Named(part)
+					}
+				}
+				l.Debug(ctx, "debug")
+				l.Info(ctx, "info")
+				l.Warn(ctx, "warn")
+			}
+
+			var got []string
+			for _, line := range decodeLines(t, out) {
+				entry := line["msg"].(string)
+				if name, ok := line["logger"].(string); ok {
+					entry = name + "/" + entry
+				}
+				got = append(got, entry)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("got = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogger_SetLevelSpec(t *testing.T) {
+	logger, out := newTestLogger(zapcore.DebugLevel)
+	db := logger.Named("db")
+	if err := logger.SetLevelSpec("error,db=debug"); err != nil {
+		t.Fatalf("SetLevelSpec() err = %v", err)
+	}
+	if err := logger.SetLevelSpec("error,db=loud"); err == nil {
+		t.Errorf("SetLevelSpec() accepted invalid spec")
+	}
+	if got := db.LevelSpec(); got != "error,db=debug" {
+		t.Errorf("LevelSpec() = %s", got)
+	}
+
+	ctx := context.Background()
+	db.Debug(ctx, "db")
+	logger.Warn(ctx, "root")
+	lines := decodeLines(t, out)
+	if len(lines) != 1 || lines[0]["msg"] != "db" {
+		t.Errorf("lines = %v, want only db debug", lines)
+	}
+
+	logger.SetLevel(zap.WarnLevel)
+	logger.Warn(ctx, "root")
+	if got := logger.LevelSpec(); got != "warn,db=debug" {
+		t.Errorf("LevelSpec() after SetLevel = %s", got)
+	}
+	if got := len(decodeLines(t, out)); got != 2 {
+		t.Errorf("lines = %d, want = 2", got)
+	}
+}
+
+func TestLevelMiddleware(t *testing.T) {
+	secret := []byte("s3cret")
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name   string
+		secret []byte
+		header string
+		want   bool
+	}{
+		{name: "signed", secret: secret, header: SignLevel(secret, zap.DebugLevel, future), want: true},
+		{name: "missing", secret: secret},
+		{name: "expired", secret: secret, header: SignLevel(secret, zap.DebugLevel, time.Now().Add(-time.Second))},
+		{name: "wrong secret", secret: secret, header: SignLevel([]byte("other"), zap.DebugLevel, future)},
+		{name: "tampered", secret: secret, header: strings.Replace(SignLevel(secret, zap.WarnLevel, future), "warn", "debug", 1)},
+		{name: "disabled", header: SignLevel(nil, zap.DebugLevel, future)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.DebugLevel)
+			logger.SetLevel(zap.InfoLevel)
+			h := LevelMiddleware(tt.secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				logger.Debug(r.Context(), "debug")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(LevelOverrideHeader, tt.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got := out.Len() > 0; got != tt.want {
+				t.Errorf("debug written = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
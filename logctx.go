@@ -2,7 +2,12 @@ package logit
 
 import (
 	"context"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -18,25 +23,64 @@ type orderedField struct {
 type LogBuffer struct {
 	mu sync.RWMutex
 
-	// FIFO ordered
-	metaOrder   []string
-	normalOrder []string
-	levelOrder  map[zapcore.Level][]string
-
-	// key → field
-	metaFields   map[string]zap.Field
-	normalFields map[string]zap.Field
-	levelFields  map[zapcore.Level]map[string]zap.Field
+	// 按写入顺序保存的字段
+	meta   *fieldStore
+	normal *fieldStore
+	levels map[zapcore.Level]*fieldStore
+	// 输出级别不低于指定级别时输出的字段
+	atLeast map[zapcore.Level]*fieldStore
+
+	// metaLoggers 以元数据派生的 logger 缓存，key 为原始 logger，元数据变化时清空
+	metaMu      sync.Mutex
+	metaLoggers map[*zap.Logger]*zap.Logger
+
+	// 已经通过 Flush 或 Begin 输出过的字段，值未变化时汇总日志不再重复输出
+	flushed map[flushKey]zap.Field
+
+	// conflict 同名字段的冲突策略
+	conflict conflictConfig
+	// normalLevel 普通字段输出的最低级别，hasNormalLevel 为 false 时使用 Logger 的配置
+	normalLevel    zapcore.Level
+	hasNormalLevel bool
+
+	// limits 容量限制，size 为 limits.MaxTotalBytes 大于 0 时字段的估算总大小
+	limits BufferLimits
+	size   int
+	// dropped 因容量限制被丢弃的字段数，在下一条日志中以 logit_dropped_fields 输出后清零
+	dropped atomic.Int64
+
+	// created 容器创建的时间，事件的偏移量以此为起点
+	created time.Time
+	// events 按偏移量排序的事件时间线，eventsDropped 为因数量上限丢弃的事件数
+	events        []event
+	eventsDropped int
+	eventOpts     EventOptions
+
+	// ctl 尾部采样缓冲区和 WithLevel 设置的级别，未设置时为 nil。
+	// 输出日志时无需加锁即可读取，保证未开启的级别不受容器锁的影响
+	ctl atomic.Pointer[outputControl]
+
+	// shared 字段是否与 Fork 出的容器共享，共享时写入前需要先拷贝
+	shared bool
+	// forkBase Fork 或上次 Join 时的字段快照，Join 时据此找出分支新增或修改的字段
+	forkBase *LogBuffer
+	// forkName 分支名称，JoinPrefix 策略下作为字段前缀
+	forkName string
+	// forkEvents Fork 时从父容器继承或上次 Join 时已合并的事件数，Join 时只合并之后记录的事件
+	forkEvents int
 }
 
 func newLogBuffer() *LogBuffer {
 	return &LogBuffer{
-		metaOrder:    []string{},
-		normalOrder:  []string{},
-		levelOrder:   map[zapcore.Level][]string{},
-		metaFields:   map[string]zap.Field{},
-		normalFields: map[string]zap.Field{},
-		levelFields:  map[zapcore.Level]map[string]zap.Field{},
+		limits:    DefaultBufferLimits,
+		created:   time.Now(),
+		eventOpts: DefaultEventOptions,
+
+		meta:    &fieldStore{},
+		normal:  &fieldStore{},
+		levels:  map[zapcore.Level]*fieldStore{},
+		atLeast: map[zapcore.Level]*fieldStore{},
+		flushed: map[flushKey]zap.Field{},
 	}
 }
 
@@ -73,63 +117,388 @@ func getBuf(ctx context.Context) *LogBuffer {
 	return nil
 }
 
-func allFields(ctx context.Context, lvl zapcore.Level, fields ...zap.Field) []zap.Field {
+// mergeOptions 合并上下文字段时的配置
+type mergeOptions struct {
+	conflict conflictConfig
+	// normalLevel 普通字段输出的最低级别，零值为 Info
+	normalLevel zapcore.Level
+	// extractors 上下文字段提取器
+	extractors []Extractor
+}
+
+// mergeOptions 返回上下文配置优先、未设置部分使用 fallback 补全的合并配置，调用方需持有锁
+func (b *LogBuffer) mergeOptions(fallback mergeOptions) mergeOptions {
+	mo := mergeOptions{
+		conflict:    b.conflict.or(fallback.conflict),
+		normalLevel: fallback.normalLevel,
+		extractors:  fallback.extractors,
+	}
+	if b.hasNormalLevel {
+		mo.normalLevel = b.normalLevel
+	}
+	return mo
+}
+
+// merge 合并容器内的字段、提取器字段和调用方字段，元数据字段位于最前面，数量记录在 m.pinned，调用方需持有锁
+func (b *LogBuffer) merge(lvl zapcore.Level, mo mergeOptions, extracted, fields []zap.Field) (*fieldMerger, mergeOptions) {
+	mo = b.mergeOptions(mo)
+	m := newFieldMerger(mo.conflict.policy)
+
+	// 1）保证元数据顺序
+	for f := range b.meta.all() {
+		m.add(CategoryMeta, f)
+	}
+	m.pinned = len(m.fields)
+	for _, f := range extracted {
+		m.add(CategoryExtracted, f)
+	}
+
+	if lvl >= mo.normalLevel {
+		// 2）普通字段顺序
+		for f := range b.normal.all() {
+			m.add(CategoryNormal, f)
+		}
+	}
+
+	// 3）不低于指定级别的字段，按级别从低到高
+	for _, minLvl := range sortedLevels(b.atLeast) {
+		if minLvl > lvl {
+			break
+		}
+		for f := range b.atLeast[minLvl].all() {
+			m.add(CategoryLevelAtLeast, f)
+		}
+	}
+
+	// 4）level 字段严格保持顺序
+	for f := range b.levels[lvl].all() {
+		m.add(CategoryLevel, f)
+	}
+	// 5）最后补充字段
+	for _, field := range fields {
+		m.add(CategoryCall, field)
+	}
+	if n := b.dropped.Swap(0); n > 0 {
+		m.add(CategoryMeta, zap.Int64(DroppedFieldsKey, n))
+	}
+	return m, mo
+}
+
+// aggregation 汇总结果
+type aggregation struct {
+	fields []zap.Field
+	// pending 本次新输出的普通字段和级别字段数量
+	pending int
+	// maxLevel 本次输出的级别字段中的最高级别
+	maxLevel zapcore.Level
+
+	merger *fieldMerger
+
+	// buf 汇总的容器，日志写入后由 done 标记已输出的字段或清空容器
+	buf   *LogBuffer
+	reset bool
+	// emitted 本次输出的普通字段、级别字段和事件字段
+	emitted []bufferedField
+	// cleared reset 为 true 时汇总时容器内的普通字段和级别字段，events 和 eventsDropped 为汇总时的事件
+	cleared       []bufferedField
+	events        []event
+	eventsDropped int
+	// dropped 本次输出的 logit_dropped_fields
+	dropped int64
+}
+
+// level 返回 base 与级别字段最高级别中的较大值，最高不超过 Error，避免触发 panic 或退出进程
+func (a aggregation) level(base zapcore.Level) zapcore.Level {
+	lvl := max(base, a.maxLevel)
+	if lvl > zap.ErrorLevel {
+		lvl = zap.ErrorLevel
+	}
+	return lvl
+}
+
+// resolve 计算延迟字段，需在确认日志级别开启后调用
+func (a aggregation) resolve() {
+	a.merger.resolve()
+}
+
+// release 写完日志后归还对象池
+func (a aggregation) release() {
+	a.merger.release()
+}
+
+// done 日志写入后（written 为 true）将本次输出的字段标记为已输出，reset 为 true 时删除汇总时的字段和事件，
+// 汇总之后新写入或修改的字段不受影响；未写入时容器保持不变，丢弃字段数留到下一条日志输出
+func (a aggregation) done(written bool) {
+	b := a.buf
+	if b == nil {
+		return
+	}
+	if !written {
+		b.dropped.Add(a.dropped)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.own()
+	if !a.reset {
+		for _, f := range a.emitted {
+			b.flushed[f.flushKey()] = f.field
+		}
+		return
+	}
+	for _, c := range a.cleared {
+		if f, ok := b.get(c.cat, c.lvl, c.field.Key); ok && fieldEqual(f, c.field) {
+			b.delete(c.cat, c.lvl, c.field.Key)
+		}
+	}
+	b.flushed = map[flushKey]zap.Field{}
+	b.events = slices.DeleteFunc(b.events, func(e event) bool {
+		return slices.ContainsFunc(a.events, e.same)
+	})
+	b.eventsDropped = max(b.eventsDropped-a.eventsDropped, 0)
+	b.recomputeSize()
+}
+
+// flushFields 汇总容器内的元数据以及尚未输出过的普通字段和级别字段，写入后需调用 done 将其标记为已输出。
+// 字段依次为元数据、提取器字段、普通字段、不低于指定级别的字段、级别字段（均按级别从低到高）和事件时间线，
+// 最后追加调用方传入的字段，与 merge 的顺序一致，同名字段按冲突策略处理。
+// reset 为 true 时 done 清空普通字段、级别字段和事件，元数据字段始终保留。
+// 延迟字段尚未计算，确认级别开启后需调用 resolve，写完日志后需调用 release 归还对象池。
+func flushFields(ctx context.Context, reset bool, mo mergeOptions, fields ...zap.Field) aggregation {
+	agg := aggregation{maxLevel: zap.DebugLevel, reset: reset}
+	extracted := mo.extract(ctx)
 	buf := getBuf(ctx)
 	if buf == nil {
-		return []zap.Field{}
+		agg.fields, agg.merger = mergeExtracted(mo, extracted, fields)
+		return agg
 	}
+	agg.buf = buf
 	buf.mu.RLock()
-	defer buf.mu.RUnlock()
-	final := make([]zap.Field, 0)
-	fkv := make(map[string]zap.Field, len(buf.normalFields)+len(buf.levelFields))
-
-	// 1）保证元数据顺序
-	for _, k := range buf.metaOrder {
-		if f, ok := buf.metaFields[k]; ok {
-			final = append(final, f)
-			fkv[k] = f
+	cc := buf.conflict.or(mo.conflict)
+	m := newFieldMerger(cc.policy)
+
+	// add 汇总一个普通字段或级别字段，已原样输出过的字段返回 false
+	add := func(cat FieldCategory, lvl zapcore.Level, f zap.Field) bool {
+		bf := bufferedField{cat: cat, lvl: lvl, field: f}
+		if reset {
+			agg.cleared = append(agg.cleared, bf)
+		}
+		if buf.isFlushed(bf) {
+			return false
 		}
+		m.add(cat, f)
+		agg.emitted = append(agg.emitted, bf)
+		return true
 	}
 
-	if lvl == zap.InfoLevel {
-		// 2）普通字段顺序
-		for _, k := range buf.normalOrder {
-			if f, ok := buf.normalFields[k]; ok {
-				if _, ok := fkv[k]; !ok {
-					// 这里不能覆盖元数据字段
-					final = append(final, f)
-				}
+	for f := range buf.meta.all() {
+		m.add(CategoryMeta, f)
+	}
+	for _, f := range extracted {
+		m.add(CategoryExtracted, f)
+	}
+	for f := range buf.normal.all() {
+		add(CategoryNormal, zapcore.InfoLevel, f)
+	}
+	for _, lvl := range sortedLevels(buf.atLeast) {
+		for f := range buf.atLeast[lvl].all() {
+			if add(CategoryLevelAtLeast, lvl, f) {
+				agg.maxLevel = max(agg.maxLevel, lvl)
 			}
 		}
 	}
-
-	// 3）level 字段严格保持顺序
-	for _, k := range buf.levelOrder[lvl] {
-		if f, ok := buf.levelFields[lvl][k]; ok {
-			if _, ok := fkv[k]; !ok {
-				// 这里不能覆盖元数据字段
-				final = append(final, f)
+	for _, lvl := range sortedLevels(buf.levels) {
+		for f := range buf.levels[lvl].all() {
+			if add(CategoryLevel, lvl, f) {
+				agg.maxLevel = max(agg.maxLevel, lvl)
 			}
 		}
 	}
-	// 4）最后补充字段
-	for _, field := range fields {
-		if _, ok := fkv[field.Key]; !ok {
-			final = append(final, field)
+	for _, f := range buf.eventFields() {
+		bf := bufferedField{cat: categoryEvent, lvl: zapcore.InfoLevel, field: f}
+		if !buf.isFlushed(bf) {
+			m.add(CategoryNormal, f)
+			agg.emitted = append(agg.emitted, bf)
+		}
+	}
+	if reset {
+		agg.events = slices.Clone(buf.events)
+		agg.eventsDropped = buf.eventsDropped
+	}
+	for _, f := range fields {
+		m.add(CategoryCall, f)
+	}
+	if agg.dropped = buf.dropped.Swap(0); agg.dropped > 0 {
+		m.add(CategoryMeta, zap.Int64(DroppedFieldsKey, agg.dropped))
+	}
+	buf.mu.RUnlock()
+
+	agg.fields = m.fields
+	agg.pending = len(agg.emitted)
+	agg.merger = m
+
+	cc.report(m.conflicts)
+	return agg
+}
+
+// flushKey 已输出字段的标记，同名字段按类别和级别分别标记
+type flushKey struct {
+	cat FieldCategory
+	lvl zapcore.Level
+	key string
+}
+
+func (f bufferedField) flushKey() flushKey {
+	return flushKey{cat: f.cat, lvl: f.lvl, key: f.field.Key}
+}
+
+// isFlushed 判断字段是否已经在汇总日志中原样输出过，调用方需持有锁
+func (b *LogBuffer) isFlushed(f bufferedField) bool {
+	prev, ok := b.flushed[f.flushKey()]
+	return ok && fieldEqual(prev, f.field)
+}
+
+// FieldCategory 日志字段的类别
+type FieldCategory int
+
+const (
+	// CategoryMeta 元数据字段
+	CategoryMeta FieldCategory = iota
+	// CategoryNormal 普通字段
+	CategoryNormal
+	// CategoryLevel 级别字段
+	CategoryLevel
+	// CategoryLevelAtLeast 不低于指定级别时输出的字段
+	CategoryLevelAtLeast
+	// CategoryCall 调用方写日志时传入的字段
+	CategoryCall
+	// CategoryExtracted 通过 WithExtractor 注册的提取器从 context 中提取的字段
+	CategoryExtracted
+)
+
+func (c FieldCategory) String() string {
+	switch c {
+	case CategoryMeta:
+		return "meta"
+	case CategoryNormal:
+		return "normal"
+	case CategoryLevel:
+		return "level"
+	case CategoryLevelAtLeast:
+		return "level_at_least"
+	case CategoryCall:
+		return "call"
+	case CategoryExtracted:
+		return "extracted"
+	default:
+		return "unknown"
+	}
+}
+
+// get 查找指定类别的字段，调用方需持有锁
+func (b *LogBuffer) get(cat FieldCategory, lvl zapcore.Level, key string) (zap.Field, bool) {
+	return b.store(cat, lvl).get(key)
+}
+
+// put 写入指定类别的字段，同名字段覆盖且位置不变，调用方需持有写锁并已调用 own
+func (b *LogBuffer) put(cat FieldCategory, lvl zapcore.Level, field zap.Field) {
+	if b.limits.enabled() {
+		var ok bool
+		if field, ok = b.admit(cat, lvl, field); !ok {
+			return
 		}
 	}
-	return final
+	if cat == CategoryMeta {
+		b.invalidateMeta()
+	}
+	s := b.store(cat, lvl)
+	if s == nil {
+		s = &fieldStore{}
+		switch cat {
+		case CategoryLevel:
+			b.levels[lvl] = s
+		case CategoryLevelAtLeast:
+			b.atLeast[lvl] = s
+		default:
+			return
+		}
+	}
+	s.set(field)
+}
+
+// store 返回指定类别的字段存储，级别字段尚未写入时返回 nil，调用方需持有锁
+func (b *LogBuffer) store(cat FieldCategory, lvl zapcore.Level) *fieldStore {
+	switch cat {
+	case CategoryMeta:
+		return b.meta
+	case CategoryNormal:
+		return b.normal
+	case CategoryLevel:
+		return b.levels[lvl]
+	case CategoryLevelAtLeast:
+		return b.atLeast[lvl]
+	default:
+		return nil
+	}
 }
 
-// ---------------- 排序写入逻辑 ----------------
+// remove 删除指定类别的字段，key 为 "group.key" 形式时删除分组内的字段，
+// 分组删空后一并删除，调用方需持有写锁并已调用 own
+func (b *LogBuffer) remove(cat FieldCategory, lvl zapcore.Level, key string) {
+	if _, ok := b.get(cat, lvl, key); ok {
+		b.delete(cat, lvl, key)
+		return
+	}
+	root, rest, ok := strings.Cut(key, ".")
+	if !ok {
+		return
+	}
+	f, ok := b.get(cat, lvl, root)
+	if !ok {
+		return
+	}
+	group, ok := f.Interface.(fieldGroup)
+	if !ok {
+		return
+	}
+	if group = group.without(rest); len(group.fields) == 0 {
+		b.delete(cat, lvl, root)
+		return
+	}
+	b.put(cat, lvl, zap.Object(root, group))
+}
 
-func ensureOrderedUpdate(order []string, key string) []string {
-	for _, k := range order {
-		if k == key {
-			return order // key 已存在，不再重复
+// delete 删除指定类别的字段及其已输出标记，调用方需持有写锁并已调用 own
+func (b *LogBuffer) delete(cat FieldCategory, lvl zapcore.Level, key string) {
+	s := b.store(cat, lvl)
+	if s == nil {
+		return
+	}
+	delete(b.flushed, flushKey{cat: cat, lvl: lvl, key: key})
+	if cat == CategoryMeta {
+		b.invalidateMeta()
+	}
+	if b.limits.MaxTotalBytes > 0 {
+		if f, ok := s.get(key); ok {
+			b.size -= fieldSize(f)
 		}
 	}
-	return append(order, key)
+	s.delete(key)
+}
+
+// own 写入前调用，如果字段仍与 Fork 出的容器共享，则先拷贝一份再写入（写时复制），调用方需持有写锁
+func (b *LogBuffer) own() {
+	if !b.shared {
+		return
+	}
+	b.meta = b.meta.clone()
+	b.normal = b.normal.clone()
+	b.levels = cloneStores(b.levels)
+	b.atLeast = cloneStores(b.atLeast)
+	b.flushed = maps.Clone(b.flushed)
+	b.shared = false
 }
 
 // ---------------- 写入字段 --------------------
@@ -141,11 +510,7 @@ func AddField(ctx context.Context, field zap.Field) {
 		return
 	}
 
-	buf.mu.Lock()
-	defer buf.mu.Unlock()
-
-	buf.normalFields[field.Key] = field
-	buf.normalOrder = ensureOrderedUpdate(buf.normalOrder, field.Key)
+	buf.setFields(CategoryNormal, zapcore.InfoLevel, field)
 }
 
 // AddMetaField 增加全局字段
@@ -159,13 +524,7 @@ func AddMetaFields(ctx context.Context, fields ...zap.Field) {
 		return
 	}
 
-	buf.mu.Lock()
-	defer buf.mu.Unlock()
-
-	for _, field := range fields {
-		buf.metaFields[field.Key] = field
-		buf.metaOrder = ensureOrderedUpdate(buf.metaOrder, field.Key)
-	}
+	buf.setFields(CategoryMeta, zapcore.InfoLevel, fields...)
 }
 
 func AddLevelField(ctx context.Context, lvl zapcore.Level, field zap.Field) {
@@ -179,19 +538,7 @@ func AddLevelFields(ctx context.Context, lvl zapcore.Level, fields ...zap.Field)
 		return
 	}
 
-	buf.mu.Lock()
-	defer buf.mu.Unlock()
-
-	if _, ok := buf.levelFields[lvl]; !ok {
-		buf.levelFields[lvl] = map[string]zap.Field{}
-		buf.levelOrder[lvl] = []string{}
-	}
-	for i := range fields {
-		field := fields[i]
-		buf.levelFields[lvl][field.Key] = field
-		buf.levelOrder[lvl] = ensureOrderedUpdate(buf.levelOrder[lvl], field.Key)
-	}
-
+	buf.setFields(CategoryLevel, lvl, fields...)
 }
 
 // AddDebug 增加 Debug 级别字段
@@ -229,33 +576,19 @@ func RemoveField(ctx context.Context, key string) {
 	buf.mu.Lock()
 	defer buf.mu.Unlock()
 
-	delete(buf.normalFields, key)
-	delete(buf.metaFields, key)
-	for lvl := range buf.levelFields {
-		delete(buf.levelFields[lvl], key)
+	buf.own()
+	buf.remove(CategoryMeta, zapcore.InfoLevel, key)
+	buf.remove(CategoryNormal, zapcore.InfoLevel, key)
+	for lvl := range buf.levels {
+		buf.remove(CategoryLevel, lvl, key)
 	}
-	for i, k := range buf.metaOrder {
-		if k == key {
-			buf.metaOrder = append(buf.metaOrder[:i], buf.metaOrder[i+1:]...)
-			break
-		}
-	}
-	for i, k := range buf.normalOrder {
-		if k == key {
-			buf.normalOrder = append(buf.normalOrder[:i], buf.normalOrder[i+1:]...)
-		}
+	for lvl := range buf.atLeast {
+		buf.remove(CategoryLevelAtLeast, lvl, key)
 	}
-	for lvl, keys := range buf.levelOrder {
-		for i, k := range keys {
-			if k == key {
-				buf.levelOrder[lvl] = append(keys[:i], keys[i+1:]...)
-			}
-		}
-	}
-
 }
 
-// FindField 查找指定字段
+// FindField 查找指定字段，按输出时的合并顺序依次查找元数据字段、普通字段、
+// 不低于指定级别的字段和级别字段（均按级别从低到高），与 Get 的顺序一致
 func FindField(ctx context.Context, key string) (zap.Field, bool) {
 	buf := getBuf(ctx)
 	if buf == nil {
@@ -265,16 +598,28 @@ func FindField(ctx context.Context, key string) (zap.Field, bool) {
 	buf.mu.RLock()
 	defer buf.mu.RUnlock()
 
-	if field, ok := buf.normalFields[key]; ok {
-		return field, true
+	return buf.find(key)
+}
+
+// find 按 元数据字段 → 普通字段 → 不低于指定级别的字段 → 级别字段 的顺序查找，调用方需持有锁
+func (b *LogBuffer) find(key string) (zap.Field, bool) {
+	for _, s := range []*fieldStore{b.meta, b.normal} {
+		if field, ok := findInFields(s, key); ok {
+			return field, true
+		}
 	}
 
-	for _, fmap := range buf.levelFields {
-		if field, ok := fmap[key]; ok {
+	for _, lvl := range sortedLevels(b.atLeast) {
+		if field, ok := findInFields(b.atLeast[lvl], key); ok {
 			return field, true
 		}
 	}
 
+	for _, lvl := range sortedLevels(b.levels) {
+		if field, ok := findInFields(b.levels[lvl], key); ok {
+			return field, true
+		}
+	}
 	return zap.Field{}, false
 }
 
@@ -287,7 +632,7 @@ func FindMetaField(ctx context.Context, key string) (zap.Field, bool) {
 
 	buf.mu.RLock()
 	defer buf.mu.RUnlock()
-	if field, ok := buf.metaFields[key]; ok {
+	if field, ok := findInFields(buf.meta, key); ok {
 		return field, true
 	}
 	return zap.Field{}, false
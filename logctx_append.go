@@ -0,0 +1,98 @@
+package logit
+
+import (
+	"context"
+	"slices"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// appendList 追加模式的列表字段，输出为数组，超出长度上限时额外输出 "<key>_truncated" 记录丢弃的数量。
+// 列表是不可变的，每次追加都会生成新的列表，避免输出时与写入并发冲突。
+type appendList struct {
+	key       string
+	values    []any
+	limit     int
+	truncated int
+}
+
+func (l appendList) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if err := enc.AddArray(l.key, anyArray(l.values)); err != nil {
+		return err
+	}
+	if l.truncated > 0 {
+		enc.AddInt(l.key+"_truncated", l.truncated)
+	}
+	return nil
+}
+
+// with 返回追加后的新列表，超出长度上限的值只计数不保存
+func (l appendList) with(values []any) appendList {
+	next := l
+	for _, v := range values {
+		if next.limit > 0 && len(next.values) >= next.limit {
+			next.truncated++
+			continue
+		}
+		next.values = append(slices.Clip(next.values), v)
+	}
+	return next
+}
+
+// field 将列表包装为 zap 字段，按内联对象输出以便同时输出截断数量
+func (l appendList) field() zap.Field {
+	return zap.Field{Key: l.key, Type: zapcore.InlineMarshalerType, Interface: l}
+}
+
+// withKey 返回改名后的字段，追加列表按自身保存的 key 输出，改名时需要一并修改
+func withKey(f zap.Field, key string) zap.Field {
+	f.Key = key
+	if l, ok := f.Interface.(appendList); ok {
+		l.key = key
+		f.Interface = l
+	}
+	return f
+}
+
+// appendValues 向指定类别的列表字段追加值，limit 大于 0 时更新列表的长度上限
+func appendValues(ctx context.Context, cat FieldCategory, lvl zapcore.Level, key string, limit int, values []any) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.own()
+	list := appendList{key: key}
+	if f, ok := buf.get(cat, lvl, key); ok {
+		list, _ = f.Interface.(appendList)
+		list.key = key
+	}
+	if limit > 0 {
+		list.limit = limit
+	}
+	buf.put(cat, lvl, list.with(values).field())
+}
+
+// Append 向列表字段追加值，例如请求中涉及的全部 SKU，列表按首次写入的位置输出为数组
+func Append(ctx context.Context, key string, values ...any) {
+	appendValues(ctx, CategoryNormal, zapcore.InfoLevel, key, 0, values)
+}
+
+// AppendLimit 向列表字段追加值并限制列表的最大长度，超出部分丢弃并输出 "<key>_truncated" 计数
+func AppendLimit(ctx context.Context, key string, limit int, values ...any) {
+	appendValues(ctx, CategoryNormal, zapcore.InfoLevel, key, limit, values)
+}
+
+// AppendLevel 向指定级别的列表字段追加值，仅对应级别的日志输出
+func AppendLevel(ctx context.Context, lvl zapcore.Level, key string, values ...any) {
+	appendValues(ctx, CategoryLevel, lvl, key, 0, values)
+}
+
+// AppendLevelLimit 向指定级别的列表字段追加值并限制列表的最大长度
+func AppendLevelLimit(ctx context.Context, lvl zapcore.Level, key string, limit int, values ...any) {
+	appendValues(ctx, CategoryLevel, lvl, key, limit, values)
+}
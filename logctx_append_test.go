@@ -0,0 +1,75 @@
+package logit
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAppend(t *testing.T) {
+	logger, out := newTestLogger(zap.DebugLevel)
+	ctx := NewContext(context.Background())
+
+	Append(ctx, "skus", "a", "b")
+	AddField(ctx, String("uid", "10001"))
+	Append(ctx, "skus", "c")
+	AppendLimit(ctx, "warnings", 2, "w1", "w2", "w3")
+	Append(ctx, "warnings", "w4")
+	AppendLevel(ctx, zap.ErrorLevel, "errors", "timeout")
+
+	logger.Info(ctx, "append")
+	want := `"skus":["a","b","c"],"uid":"10001","warnings":["w1","w2"],"warnings_truncated":2`
+	if got := out.String(); !strings.Contains(got, want) || strings.Contains(got, "timeout") {
+		t.Errorf("Info() = %s, want contains %s", got, want)
+	}
+
+	out.Reset()
+	logger.Error(ctx, "append")
+	if got := out.String(); !strings.Contains(got, `"errors":["timeout"]`) {
+		t.Errorf("Error() = %s, want contains errors", got)
+	}
+}
+
+func TestAppend_Flush(t *testing.T) {
+	logger, out := newTestLogger(zap.InfoLevel)
+	ctx := NewContext(context.Background())
+
+	Append(ctx, "skus", "a")
+	logger.Flush(ctx)
+	out.Reset()
+	logger.Flush(ctx)
+	if strings.Contains(out.String(), `"skus"`) {
+		t.Errorf("Flush() re-emitted unchanged skus: %s", out.String())
+	}
+
+	Append(ctx, "skus", "b")
+	logger.Flush(ctx)
+	if !strings.Contains(out.String(), `"skus":["a","b"]`) {
+		t.Errorf("Flush() after Append() = %s, want skus [a b]", out.String())
+	}
+}
+
+func TestAppend_Rename(t *testing.T) {
+	logger, out := newTestLogger(zap.InfoLevel)
+	logger = logger.WithLoggerOptions(WithConflictPolicy(ConflictRename))
+	ctx := NewContext(context.Background())
+	AddMetaField(ctx, String("skus", "meta"))
+	AppendLimit(ctx, "skus", 1, "a", "b")
+
+	logger.Info(ctx, "renamed")
+	want := `"skus":"meta","skus_1":["a"],"skus_1_truncated":1`
+	if got := out.String(); !strings.Contains(got, want) {
+		t.Errorf("Info() = %s, want contains %s", got, want)
+	}
+
+	child := ForkNamed(ctx, "db")
+	Append(child, "skus", "c")
+	JoinWith(ctx, JoinPrefix, child)
+	out.Reset()
+	logger.Info(ctx, "prefixed")
+	if got := out.String(); !strings.Contains(got, `"db.skus":["a"],"db.skus_truncated":2`) {
+		t.Errorf("Info() after JoinPrefix = %s, want contains db.skus", got)
+	}
+}
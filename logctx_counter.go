@@ -0,0 +1,117 @@
+package logit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// timerStat 计时器统计，输出为 {"count":1,"total":"3ms","max":"3ms"}
+type timerStat struct {
+	Count int64
+	Total time.Duration
+	Max   time.Duration
+}
+
+func (s timerStat) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt64("count", s.Count)
+	enc.AddDuration("total", s.Total)
+	enc.AddDuration("max", s.Max)
+	return nil
+}
+
+// accumulator 标记由 Incr、AddDuration 写入的累加字段，Join 时按分支的增量合并
+type accumulator struct{}
+
+// isAccumulated 判断是否为 Incr、AddDuration、StartTimer 写入的累加字段
+func isAccumulated(f zap.Field) bool {
+	switch f.Interface.(type) {
+	case accumulator, timerStat:
+		return true
+	default:
+		return false
+	}
+}
+
+// mergeAccumulated 将分支中累加字段相对 Fork 时 base 的增量累加到父容器的 parent 上，
+// parent 或 base 不是同类的累加字段时按 0 计算，计时器的最大耗时取两者中的较大值
+func mergeAccumulated(parent, child, base zap.Field) zap.Field {
+	if !isAccumulated(base) || base.Type != child.Type {
+		base = zap.Field{}
+	}
+	if !isAccumulated(parent) || parent.Type != child.Type {
+		parent = zap.Field{}
+	}
+	if stat, ok := child.Interface.(timerStat); ok {
+		prev, _ := base.Interface.(timerStat)
+		merged, _ := parent.Interface.(timerStat)
+		merged.Count += stat.Count - prev.Count
+		merged.Total += stat.Total - prev.Total
+		merged.Max = max(merged.Max, stat.Max)
+		return zap.Object(child.Key, merged)
+	}
+	child.Integer += parent.Integer - base.Integer
+	return child
+}
+
+// update 在写锁内读取普通字段的当前值并写入新值，保证多个 goroutine 并发累加时不丢失
+func update(ctx context.Context, key string, fn func(prev zap.Field, ok bool) zap.Field) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.own()
+	prev, ok := buf.get(CategoryNormal, zapcore.InfoLevel, key)
+	buf.put(CategoryNormal, zapcore.InfoLevel, fn(prev, ok))
+}
+
+// Incr 累加计数字段，例如 DB 调用次数，字段不存在或不是整数时从 0 开始计数。
+// 计数字段、耗时字段和计时字段在 Join 时将分支的增量累加到父容器，不受 JoinPolicy 影响
+func Incr(ctx context.Context, key string, n int64) {
+	update(ctx, key, func(prev zap.Field, ok bool) zap.Field {
+		if ok && prev.Type == zapcore.Int64Type {
+			n += prev.Integer
+		}
+		return zap.Field{Key: key, Type: zapcore.Int64Type, Integer: n, Interface: accumulator{}}
+	})
+}
+
+// AddDuration 累加耗时字段，例如 redis 总耗时，字段不存在或不是耗时时从 0 开始累加
+func AddDuration(ctx context.Context, key string, d time.Duration) {
+	update(ctx, key, func(prev zap.Field, ok bool) zap.Field {
+		if ok && prev.Type == zapcore.DurationType {
+			d += time.Duration(prev.Integer)
+		}
+		return zap.Field{Key: key, Type: zapcore.DurationType, Integer: int64(d), Interface: accumulator{}}
+	})
+}
+
+// StartTimer 开始计时，调用返回的 stop 函数结束计时并累加到计时字段，
+// 字段输出为包含调用次数、总耗时和最大耗时的对象。stop 多次调用只生效一次。
+func StartTimer(ctx context.Context, key string) (stop func()) {
+	start := time.Now()
+	var done atomic.Bool
+	return func() {
+		if !done.CompareAndSwap(false, true) {
+			return
+		}
+		elapsed := time.Since(start)
+		update(ctx, key, func(prev zap.Field, ok bool) zap.Field {
+			var stat timerStat
+			if ok {
+				stat, _ = prev.Interface.(timerStat)
+			}
+			stat.Count++
+			stat.Total += elapsed
+			stat.Max = max(stat.Max, elapsed)
+			return zap.Object(key, stat)
+		})
+	}
+}
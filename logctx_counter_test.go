@@ -0,0 +1,78 @@
+package logit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCounters(t *testing.T) {
+	logger, out := newTestLogger(zap.DebugLevel)
+	ctx := NewContext(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Incr(ctx, "db_calls", 1)
+			AddDuration(ctx, "redis_cost", time.Millisecond)
+			stop := StartTimer(ctx, "rpc")
+			stop()
+			stop()
+		}()
+	}
+	wg.Wait()
+
+	if f, _ := FindField(ctx, "db_calls"); f.Integer != 50 {
+		t.Errorf("Incr() = %d, want = 50", f.Integer)
+	}
+	if f, _ := FindField(ctx, "redis_cost"); time.Duration(f.Integer) != 50*time.Millisecond {
+		t.Errorf("AddDuration() = %v, want = %v", time.Duration(f.Integer), 50*time.Millisecond)
+	}
+	f, _ := FindField(ctx, "rpc")
+	if stat, ok := f.Interface.(timerStat); !ok || stat.Count != 50 || stat.Max > stat.Total {
+		t.Errorf("StartTimer() = %+v, want count = 50", f.Interface)
+	}
+
+	logger.Info(ctx, "counters")
+	for _, want := range []string{`"db_calls":50`, `"redis_cost":"50ms"`, `"rpc":{"count":50,"total":`} {
+		if got := out.String(); !strings.Contains(got, want) {
+			t.Errorf("Info() = %s, want contains %s", got, want)
+		}
+	}
+}
+
+func TestCounters_Join(t *testing.T) {
+	for _, policy := range []JoinPolicy{JoinLastWins, JoinKeepFirst, JoinPrefix, JoinCollect} {
+		ctx := NewContext(context.Background())
+		Incr(ctx, "db", 1)
+		AddDuration(ctx, "redis", time.Millisecond)
+		StartTimer(ctx, "rpc")()
+
+		children := []context.Context{Fork(ctx), Fork(ctx)}
+		for _, child := range children {
+			Incr(child, "db", 1)
+			AddDuration(child, "redis", time.Millisecond)
+			StartTimer(child, "rpc")()
+		}
+		Incr(ctx, "db", 1)
+		JoinWith(ctx, policy, children...)
+		// 重复 Join 不会重复累加
+		JoinWith(ctx, policy, children...)
+
+		if f, _ := FindField(ctx, "db"); f.Integer != 4 {
+			t.Errorf("policy %d: db = %d, want = 4", policy, f.Integer)
+		}
+		if f, _ := FindField(ctx, "redis"); time.Duration(f.Integer) != 3*time.Millisecond {
+			t.Errorf("policy %d: redis = %v, want = 3ms", policy, time.Duration(f.Integer))
+		}
+		if f, _ := FindField(ctx, "rpc"); f.Interface.(timerStat).Count != 3 {
+			t.Errorf("policy %d: rpc count = %d, want = 3", policy, f.Interface.(timerStat).Count)
+		}
+	}
+}
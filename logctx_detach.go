@@ -0,0 +1,77 @@
+package logit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// RequestIDKey 请求标识的元数据字段，Detach 时父容器缺少该字段会自动生成
+	RequestIDKey = "request_id"
+	// ParentRequestKey Detach 出的容器中记录父请求标识的元数据字段
+	ParentRequestKey = "parent_request"
+)
+
+// Detach 为生命周期超过当前请求的后台任务派生上下文，通常用于 go sendEmail(logit.Detach(ctx))。
+// 返回的上下文不会随 ctx 取消，携带 ctx 中元数据字段和普通字段的快照，之后双方的写入互不影响。
+// 快照中的 request_id 替换为 parent_request，值为父容器的 request_id，父容器没有时生成一个并写入父容器，
+// 以便后台任务的日志与请求的日志关联。WithLevel 设置的级别会保留，级别字段、Flush 记录和尾部采样缓冲区不会带入新容器。
+func Detach(ctx context.Context) context.Context {
+	detached := context.WithoutCancel(ctx)
+	parent := getBuf(ctx)
+	if parent == nil {
+		return NewContext(detached)
+	}
+
+	id := parent.requestID()
+
+	child := newLogBuffer()
+	parent.mu.RLock()
+	child.meta = parent.meta.clone()
+	child.normal = parent.normal.clone()
+	child.conflict = parent.conflict
+	child.normalLevel = parent.normalLevel
+	child.hasNormalLevel = parent.hasNormalLevel
+	child.limits = parent.limits
+	parent.mu.RUnlock()
+
+	if ctl := parent.control(); ctl.hasLevel {
+		child.setControl(func(c *outputControl) {
+			c.level, c.hasLevel = ctl.level, true
+		})
+	}
+	child.meta.delete(RequestIDKey)
+	child.meta.set(zap.String(ParentRequestKey, id))
+	child.recomputeSize()
+	return context.WithValue(detached, ctxKey{}, child)
+}
+
+// requestID 返回元数据中的 request_id，不存在时生成一个并写入元数据
+func (b *LogBuffer) requestID() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if f, ok := b.meta.get(RequestIDKey); ok {
+		if s, ok := baggageValue(f); ok {
+			return s
+		}
+		return fmt.Sprint(fieldValue(f))
+	}
+
+	id := newRequestID()
+	b.own()
+	b.put(CategoryMeta, zapcore.InfoLevel, zap.String(RequestIDKey, id))
+	return id
+}
+
+// newRequestID 生成 16 字节的随机十六进制标识
+func newRequestID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
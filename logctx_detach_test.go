@@ -0,0 +1,91 @@
+package logit
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDetach(t *testing.T) {
+	tests := []struct {
+		name       string
+		prepare    func(ctx context.Context)
+		wantParent string
+	}{
+		{
+			name: "existing request id",
+			prepare: func(ctx context.Context) {
+				AddMetaField(ctx, String(RequestIDKey, "req-1"))
+			},
+			wantParent: "req-1",
+		},
+		{
+			name: "int request id",
+			prepare: func(ctx context.Context) {
+				AddMetaField(ctx, Int64(RequestIDKey, 42))
+			},
+			wantParent: "42",
+		},
+		{
+			name: "generated request id",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent, cancel := context.WithCancel(NewContext(context.Background()))
+			AddMetaField(parent, String("trace_id", "abc"))
+			AddField(parent, String("uid", "10001"))
+			AddDebug(parent, String("sql", "select 1"))
+			if tt.prepare != nil {
+				tt.prepare(parent)
+			}
+
+			child := Detach(parent)
+			cancel()
+			if child.Err() != nil {
+				t.Errorf("Detach() ctx canceled with parent: %v", child.Err())
+			}
+
+			reqID, ok := FindMetaField(parent, RequestIDKey)
+			if !ok {
+				t.Fatalf("parent missing %s", RequestIDKey)
+			}
+			want := tt.wantParent
+			if want == "" {
+				want = reqID.String
+				if len(want) != 32 {
+					t.Errorf("generated request id = %q", want)
+				}
+			}
+
+			got := Snapshot(child)
+			if got[ParentRequestKey] != want || got["trace_id"] != "abc" || got["uid"] != "10001" {
+				t.Errorf("Detach() snapshot = %v, want parent_request = %s", got, want)
+			}
+			if _, ok := got[RequestIDKey]; ok {
+				t.Errorf("Detach() snapshot kept %s", RequestIDKey)
+			}
+			if _, ok := got["sql"]; ok {
+				t.Errorf("Detach() snapshot kept level field")
+			}
+
+			AddField(child, String("uid", "changed"))
+			AddField(parent, String("order", "1"))
+			if s := Snapshot(parent); s["uid"] != "10001" {
+				t.Errorf("parent uid = %v after child write", s["uid"])
+			}
+			if _, ok := Snapshot(child)["order"]; ok {
+				t.Errorf("child saw parent write")
+			}
+		})
+	}
+}
+
+func TestDetach_NoBuffer(t *testing.T) {
+	ctx := Detach(context.Background())
+	AddField(ctx, zap.String("uid", "1"))
+	if got := Snapshot(ctx); got["uid"] != "1" {
+		t.Errorf("Detach() without buffer snapshot = %v", got)
+	}
+}
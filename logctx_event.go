@@ -0,0 +1,195 @@
+package logit
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// EventsKey 汇总日志中事件时间线的字段名
+	EventsKey = "events"
+	// EventsTruncatedKey 因数量上限被丢弃的最早事件数
+	EventsTruncatedKey = "events_truncated"
+)
+
+// categoryEvent 事件时间线字段在已输出标记中使用的类别，不对外暴露
+const categoryEvent FieldCategory = -1
+
+// EventOptions 事件时间线的配置
+type EventOptions struct {
+	// MaxEvents 最多保存的事件数，超出时丢弃最早的事件，小于等于 0 时使用 64
+	MaxEvents int
+	// Level 记录事件的最低级别，低于该级别的事件直接忽略，零值为 Info
+	Level zapcore.Level
+}
+
+// defaultMaxEvents MaxEvents 未设置时的事件数上限
+const defaultMaxEvents = 64
+
+// DefaultEventOptions 新建日志容器时使用的事件配置，应在初始化阶段设置
+var DefaultEventOptions = EventOptions{MaxEvents: defaultMaxEvents}
+
+func (o EventOptions) maxEvents() int {
+	if o.MaxEvents <= 0 {
+		return defaultMaxEvents
+	}
+	return o.MaxEvents
+}
+
+// event 时间线中的一个事件，offset 为距离容器创建的时间
+type event struct {
+	name   string
+	level  zapcore.Level
+	offset time.Duration
+	fields []zap.Field
+}
+
+// MarshalLogObject 输出事件名称、偏移量和附带的字段，级别不是 Info 时额外输出级别
+func (e event) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", e.name)
+	enc.AddDuration("offset", e.offset)
+	if e.level != zapcore.InfoLevel {
+		enc.AddString("level", e.level.String())
+	}
+	for _, f := range e.fields {
+		f.AddTo(enc)
+	}
+	return nil
+}
+
+// same 判断是否为同一事件
+func (e event) same(o event) bool {
+	return e.name == o.name && e.level == o.level && e.offset == o.offset
+}
+
+// eventList 按发生顺序输出的事件数组
+type eventList []event
+
+func (l eventList) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, e := range l {
+		if err := enc.AppendObject(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetEventOptions 设置上下文日志容器的事件配置，只作用于之后记录的事件
+func SetEventOptions(ctx context.Context, opts EventOptions) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.eventOpts = opts
+}
+
+// AddEvent 以 Info 级别记录一个事件，事件按发生顺序以 events 数组输出在 Begin、Flush、Recover 的汇总日志中，
+// 每个事件包含名称、距离容器创建的偏移量以及附带的字段
+func AddEvent(ctx context.Context, name string, fields ...zap.Field) {
+	AddLevelEvent(ctx, zapcore.InfoLevel, name, fields...)
+}
+
+// AddLevelEvent 记录指定级别的事件，低于 EventOptions.Level 的事件会被忽略。
+// 事件附带的字段与其他字段一样受 BufferLimits 限制，见 addEvents
+func AddLevelEvent(ctx context.Context, lvl zapcore.Level, name string, fields ...zap.Field) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+	now := time.Now()
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	if lvl < buf.eventOpts.Level {
+		return
+	}
+	fields = slices.Clone(fields)
+	for i, f := range fields {
+		fields[i] = truncateField(f, buf.limits.MaxValueBytes)
+	}
+	buf.addEvents(event{name: name, level: lvl, offset: now.Sub(buf.created), fields: fields})
+}
+
+// addEvents 按偏移量顺序追加事件，超出 MaxEvents 时丢弃最早的事件。
+// 设置了 MaxTotalBytes 时事件计入估算总大小，超出时与其他字段一样按 Policy 处理：
+// LimitEvictOldest 丢弃最早的事件并计入 events_truncated，LimitReject 忽略新事件并计入 logit_dropped_fields。
+// 调用方需持有写锁
+func (b *LogBuffer) addEvents(events ...event) {
+	if b.limits.MaxTotalBytes > 0 {
+		admitted := make([]event, 0, len(events))
+		for _, e := range events {
+			if b.admitEvent(e) {
+				admitted = append(admitted, e)
+			}
+		}
+		events = admitted
+	}
+	if len(events) == 0 {
+		return
+	}
+	b.events = append(b.events, events...)
+	if len(events) > 1 || len(b.events) > 1 && b.events[len(b.events)-2].offset > events[0].offset {
+		slices.SortStableFunc(b.events, func(a, c event) int {
+			return cmp.Compare(a.offset, c.offset)
+		})
+	}
+	if n := len(b.events) - b.eventOpts.maxEvents(); n > 0 {
+		b.dropEvents(n)
+	}
+}
+
+// admitEvent 按 MaxTotalBytes 检查待记录的事件，允许记录时计入估算总大小，调用方需持有写锁
+func (b *LogBuffer) admitEvent(e event) bool {
+	size := e.size()
+	for b.size+size > b.limits.MaxTotalBytes {
+		if b.limits.Policy != LimitEvictOldest || len(b.events) == 0 {
+			b.dropped.Add(1)
+			return false
+		}
+		b.dropEvents(1)
+	}
+	b.size += size
+	return true
+}
+
+// dropEvents 丢弃最早的 n 个事件，计入 events_truncated，调用方需持有写锁
+func (b *LogBuffer) dropEvents(n int) {
+	if b.limits.MaxTotalBytes > 0 {
+		for _, e := range b.events[:n] {
+			b.size -= e.size()
+		}
+	}
+	b.events = b.events[n:]
+	b.eventsDropped += n
+}
+
+// size 估算事件占用的字节数
+func (e event) size() int {
+	size := len(e.name) + 24
+	for _, f := range e.fields {
+		size += fieldSize(f)
+	}
+	return size
+}
+
+// eventFields 返回事件时间线对应的字段，没有事件时返回 nil，调用方需持有锁
+func (b *LogBuffer) eventFields() []zap.Field {
+	if len(b.events) == 0 {
+		return nil
+	}
+	fields := []zap.Field{zap.Array(EventsKey, slices.Clone(eventList(b.events)))}
+	if b.eventsDropped > 0 {
+		fields = append(fields, zap.Int(EventsTruncatedKey, b.eventsDropped))
+	}
+	return fields
+}
@@ -0,0 +1,210 @@
+package logit
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// eventNames 返回日志行中 events 数组的事件名称
+func eventNames(line map[string]any) []string {
+	var names []string
+	events, _ := line[EventsKey].([]any)
+	for _, e := range events {
+		m, _ := e.(map[string]any)
+		names = append(names, m["name"].(string))
+	}
+	return names
+}
+
+func TestAddEvent(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          EventOptions
+		run           func(ctx context.Context)
+		wantNames     []string
+		wantTruncated any
+	}{
+		{
+			name: "ordered",
+			run: func(ctx context.Context) {
+				AddEvent(ctx, "auth")
+				AddEvent(ctx, "db", String("table", "user"))
+				AddLevelEvent(ctx, zap.ErrorLevel, "rpc failed")
+			},
+			wantNames: []string{"auth", "db", "rpc failed"},
+		},
+		{
+			name: "level filter",
+			opts: EventOptions{Level: zap.WarnLevel},
+			run: func(ctx context.Context) {
+				AddEvent(ctx, "auth")
+				AddLevelEvent(ctx, zap.DebugLevel, "cache")
+				AddLevelEvent(ctx, zap.WarnLevel, "slow")
+			},
+			wantNames: []string{"slow"},
+		},
+		{
+			name: "cap drops oldest",
+			opts: EventOptions{MaxEvents: 2},
+			run: func(ctx context.Context) {
+				AddEvent(ctx, "e1")
+				AddEvent(ctx, "e2")
+				AddEvent(ctx, "e3")
+			},
+			wantNames:     []string{"e2", "e3"},
+			wantTruncated: float64(1),
+		},
+		{
+			name: "fork join",
+			run: func(ctx context.Context) {
+				AddEvent(ctx, "start")
+				child := Fork(ctx)
+				AddEvent(child, "branch")
+				AddEvent(ctx, "parent")
+				Join(ctx, child)
+			},
+			wantNames: []string{"start", "branch", "parent"},
+		},
+		{
+			name: "join twice",
+			run: func(ctx context.Context) {
+				child := Fork(ctx)
+				AddEvent(child, "e1")
+				Join(ctx, child)
+				Join(ctx, child)
+				AddEvent(child, "e2")
+				Join(ctx, child, child)
+			},
+			wantNames: []string{"e1", "e2"},
+		},
+		{
+			name: "none",
+			run:  func(ctx context.Context) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.InfoLevel)
+			ctx := NewContext(context.Background())
+			if tt.opts != (EventOptions{}) {
+				SetEventOptions(ctx, tt.opts)
+			}
+			finish := logger.Begin(ctx, "request")
+			tt.run(ctx)
+			finish(errors.New("boom"))
+
+			lines := decodeLines(t, out)
+			if len(lines) != 1 {
+				t.Fatalf("lines = %d, want = 1", len(lines))
+			}
+			if got := eventNames(lines[0]); !slices.Equal(got, tt.wantNames) {
+				t.Errorf("events = %v, want = %v", got, tt.wantNames)
+			}
+			if got := lines[0][EventsTruncatedKey]; got != tt.wantTruncated {
+				t.Errorf("%s = %v, want = %v", EventsTruncatedKey, got, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestAddEvent_Fields(t *testing.T) {
+	logger, out := newTestLogger(zap.InfoLevel)
+	ctx := NewContext(context.Background())
+	AddEvent(ctx, "db", String("table", "user"))
+	AddLevelEvent(ctx, zap.WarnLevel, "slow")
+	logger.Flush(ctx)
+	logger.Flush(ctx)
+
+	lines := decodeLines(t, out)
+	if len(lines) != 1 {
+		t.Fatalf("lines = %d, want = 1, unchanged events must not be flushed twice", len(lines))
+	}
+	events, _ := lines[0][EventsKey].([]any)
+	if len(events) != 2 {
+		t.Fatalf("events = %v", lines[0][EventsKey])
+	}
+	db, _ := events[0].(map[string]any)
+	if db["table"] != "user" || db["offset"] == nil || db["level"] != nil {
+		t.Errorf("db event = %v", db)
+	}
+	slow, _ := events[1].(map[string]any)
+	if slow["level"] != "warn" {
+		t.Errorf("slow event = %v", slow)
+	}
+}
+
+func TestAddEvent_Limits(t *testing.T) {
+	tests := []struct {
+		name          string
+		limits        BufferLimits
+		run           func(ctx context.Context)
+		wantNames     []string
+		wantTruncated any
+		wantDropped   any
+	}{
+		{
+			name:   "value bytes",
+			limits: BufferLimits{MaxValueBytes: 4},
+			run: func(ctx context.Context) {
+				AddEvent(ctx, "db", String("sql", "select 1"))
+			},
+			wantNames: []string{"db"},
+		},
+		{
+			name:   "total bytes reject",
+			limits: BufferLimits{MaxTotalBytes: 200},
+			run: func(ctx context.Context) {
+				AddEvent(ctx, "auth")
+				AddEvent(ctx, "body", String("payload", strings.Repeat("x", 15000)))
+				AddEvent(ctx, "db")
+			},
+			wantNames:   []string{"auth", "db"},
+			wantDropped: float64(1),
+		},
+		{
+			name:   "total bytes evict",
+			limits: BufferLimits{MaxTotalBytes: 200, Policy: LimitEvictOldest},
+			run: func(ctx context.Context) {
+				for _, name := range []string{"e1", "e2", "e3", "e4"} {
+					AddEvent(ctx, name, String("payload", strings.Repeat("x", 40)))
+				}
+			},
+			wantNames:     []string{"e3", "e4"},
+			wantTruncated: float64(2),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.InfoLevel)
+			ctx := NewContext(context.Background())
+			SetBufferLimits(ctx, tt.limits)
+			tt.run(ctx)
+			logger.Flush(ctx)
+
+			if out.Len() > 1024 {
+				t.Fatalf("Flush() line is %d bytes, want bounded by limits", out.Len())
+			}
+			lines := decodeLines(t, out)
+			if len(lines) != 1 {
+				t.Fatalf("lines = %d, want = 1", len(lines))
+			}
+			if got := eventNames(lines[0]); !slices.Equal(got, tt.wantNames) {
+				t.Errorf("events = %v, want = %v", got, tt.wantNames)
+			}
+			if got := lines[0][EventsTruncatedKey]; got != tt.wantTruncated {
+				t.Errorf("%s = %v, want = %v", EventsTruncatedKey, got, tt.wantTruncated)
+			}
+			if got := lines[0][DroppedFieldsKey]; got != tt.wantDropped {
+				t.Errorf("%s = %v, want = %v", DroppedFieldsKey, got, tt.wantDropped)
+			}
+			if tt.limits.MaxValueBytes > 0 && !strings.Contains(out.String(), `"sql":"sele"`) {
+				t.Errorf("Flush() = %s, want truncated event field", out.String())
+			}
+		})
+	}
+}
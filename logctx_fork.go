@@ -0,0 +1,238 @@
+package logit
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// JoinPolicy Join 合并分支字段时的冲突处理策略
+type JoinPolicy int
+
+const (
+	// JoinLastWins 后合并的值覆盖已有的值，位置不变
+	JoinLastWins JoinPolicy = iota
+	// JoinKeepFirst 保留已有的值，忽略分支中的同名字段
+	JoinKeepFirst
+	// JoinPrefix 分支字段统一以 "分支名." 为前缀写入，未命名的分支使用 branch_<序号>
+	JoinPrefix
+	// JoinCollect 同名且值不同的字段收集为数组
+	JoinCollect
+)
+
+// Fork 为并发的子任务派生一个日志容器，子容器初始包含父容器的全部字段，
+// 双方共享字段直到任意一方写入时才拷贝（写时复制），互不影响。
+// 子任务结束后通过 Join 将新增或修改的字段合并回父容器。
+func Fork(ctx context.Context) context.Context {
+	return ForkNamed(ctx, "")
+}
+
+// ForkNamed 派生一个带名称的日志容器，名称在 JoinPrefix 策略下作为字段前缀
+func ForkNamed(ctx context.Context, name string) context.Context {
+	parent := findKeyCtx(ctx)
+	if parent == nil {
+		child := newLogBuffer()
+		child.forkName = name
+		return context.WithValue(ctx, ctxKey{}, child)
+	}
+
+	parent.mu.Lock()
+	defer parent.mu.Unlock()
+
+	parent.shared = true
+	child := &LogBuffer{
+		meta:    parent.meta,
+		normal:  parent.normal,
+		levels:  parent.levels,
+		atLeast: parent.atLeast,
+		flushed: parent.flushed,
+
+		conflict:       parent.conflict,
+		normalLevel:    parent.normalLevel,
+		hasNormalLevel: parent.hasNormalLevel,
+		limits:         parent.limits,
+		size:           parent.size,
+		shared:         true,
+		forkName:       name,
+
+		created:       parent.created,
+		events:        slices.Clone(parent.events),
+		eventsDropped: parent.eventsDropped,
+		eventOpts:     parent.eventOpts,
+		forkEvents:    len(parent.events),
+	}
+	child.forkBase = child.forkSnapshot()
+	child.ctl.Store(parent.ctl.Load())
+	return context.WithValue(ctx, ctxKey{}, child)
+}
+
+// Join 将分支中新增或修改的字段合并回父容器，同名字段后合并的覆盖先合并的。
+// 同一分支可以多次 Join，每次只合并上次 Join 之后的变化
+func Join(parent context.Context, children ...context.Context) {
+	JoinWith(parent, JoinLastWins, children...)
+}
+
+// JoinWith 按指定的冲突策略将分支中新增或修改的字段合并回父容器，分支按参数顺序合并
+func JoinWith(parent context.Context, policy JoinPolicy, children ...context.Context) {
+	pbuf := getBuf(parent)
+	if pbuf == nil {
+		return
+	}
+
+	type branch struct {
+		name    string
+		changes []forkChange
+	}
+	branches := make([]branch, 0, len(children))
+	var events []event
+	for i, child := range children {
+		cbuf := findKeyCtx(child)
+		if cbuf == nil || cbuf == pbuf {
+			continue
+		}
+		name := cbuf.forkName
+		if name == "" {
+			name = "branch_" + strconv.Itoa(i)
+		}
+		changes, forked := cbuf.takeForked()
+		branches = append(branches, branch{name: name, changes: changes})
+		events = append(events, forked...)
+	}
+
+	pbuf.mu.Lock()
+	defer pbuf.mu.Unlock()
+
+	pbuf.own()
+	if len(events) > 0 {
+		pbuf.addEvents(events...)
+	}
+	collected := map[forkSlot][]zap.Field{}
+	var collectOrder []forkSlot
+	for _, b := range branches {
+		for _, ch := range b.changes {
+			f := ch.field
+			if isAccumulated(f) {
+				existing, _ := pbuf.get(ch.cat, ch.lvl, f.Key)
+				pbuf.put(ch.cat, ch.lvl, mergeAccumulated(existing, f, ch.base))
+				continue
+			}
+			switch policy {
+			case JoinPrefix:
+				f = withKey(f, b.name+"."+f.Key)
+				pbuf.put(ch.cat, ch.lvl, f)
+			case JoinKeepFirst:
+				if _, ok := pbuf.get(ch.cat, ch.lvl, f.Key); !ok {
+					pbuf.put(ch.cat, ch.lvl, f)
+				}
+			case JoinCollect:
+				slot := forkSlot{cat: ch.cat, lvl: ch.lvl, key: f.Key}
+				values, collecting := collected[slot]
+				if !collecting {
+					existing, ok := pbuf.get(ch.cat, ch.lvl, f.Key)
+					if !ok || fieldEqual(existing, f) {
+						pbuf.put(ch.cat, ch.lvl, f)
+						continue
+					}
+					values = []zap.Field{existing}
+					collectOrder = append(collectOrder, slot)
+				}
+				collected[slot] = append(values, f)
+			default:
+				pbuf.put(ch.cat, ch.lvl, f)
+			}
+		}
+	}
+
+	for _, slot := range collectOrder {
+		values := make(anyArray, 0, len(collected[slot]))
+		for _, f := range collected[slot] {
+			values = append(values, fieldValue(f))
+		}
+		pbuf.put(slot.cat, slot.lvl, zap.Array(slot.key, values))
+	}
+}
+
+// forkSlot 定位容器内的一个字段
+type forkSlot struct {
+	cat FieldCategory
+	lvl zapcore.Level
+	key string
+}
+
+// forkChange 分支中新增或修改的字段，base 为 Fork 或上次 Join 时的值
+type forkChange struct {
+	cat   FieldCategory
+	lvl   zapcore.Level
+	field zap.Field
+	base  zap.Field
+}
+
+// forkSnapshot 返回当前字段的快照作为 Join 的基准，快照与容器共享字段，调用方需持有写锁并将 shared 置为 true
+func (b *LogBuffer) forkSnapshot() *LogBuffer {
+	return &LogBuffer{
+		meta:    b.meta,
+		normal:  b.normal,
+		levels:  b.levels,
+		atLeast: b.atLeast,
+
+		eventsDropped: b.eventsDropped,
+	}
+}
+
+// takeForked 返回相对 Fork 或上次 Join 新增或修改的字段和之后记录的事件，并以当前状态作为下次 Join 的基准
+func (b *LogBuffer) takeForked() ([]forkChange, []event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changes, events := b.forkChanges(), b.forkedEvents()
+	b.shared = true
+	b.forkBase = b.forkSnapshot()
+	b.forkEvents = len(b.events)
+	return changes, events
+}
+
+// forkedEvents 返回 Fork 之后记录的事件，调用方需持有锁
+func (b *LogBuffer) forkedEvents() []event {
+	if b.forkBase == nil {
+		return slices.Clone(b.events)
+	}
+	// 超出上限丢弃过最早的事件时，继承的事件可能已被丢弃
+	n := max(b.forkEvents-(b.eventsDropped-b.forkBase.eventsDropped), 0)
+	return slices.Clone(b.events[n:])
+}
+
+// forkChanges 按元数据、普通字段、不低于指定级别的字段、级别字段（从低到高）的顺序返回相对 Fork 时新增或修改的字段，调用方需持有锁
+func (b *LogBuffer) forkChanges() []forkChange {
+	var changes []forkChange
+	add := func(cat FieldCategory, lvl zapcore.Level, f zap.Field) {
+		var prev zap.Field
+		if b.forkBase != nil {
+			var ok bool
+			if prev, ok = b.forkBase.get(cat, lvl, f.Key); ok && fieldEqual(prev, f) {
+				return
+			}
+		}
+		changes = append(changes, forkChange{cat: cat, lvl: lvl, field: f, base: prev})
+	}
+
+	for f := range b.meta.all() {
+		add(CategoryMeta, zapcore.InfoLevel, f)
+	}
+	for f := range b.normal.all() {
+		add(CategoryNormal, zapcore.InfoLevel, f)
+	}
+	for _, lvl := range sortedLevels(b.atLeast) {
+		for f := range b.atLeast[lvl].all() {
+			add(CategoryLevelAtLeast, lvl, f)
+		}
+	}
+	for _, lvl := range sortedLevels(b.levels) {
+		for f := range b.levels[lvl].all() {
+			add(CategoryLevel, lvl, f)
+		}
+	}
+	return changes
+}
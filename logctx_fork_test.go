@@ -0,0 +1,95 @@
+package logit
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestJoinWith(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy JoinPolicy
+		want   map[string]any
+	}{
+		{
+			name:   "JoinLastWins",
+			policy: JoinLastWins,
+			want:   map[string]any{"uid": "10001", "sku": "b", "cache": "hit"},
+		},
+		{
+			name:   "JoinKeepFirst",
+			policy: JoinKeepFirst,
+			want:   map[string]any{"uid": "10001", "sku": "a", "cache": "hit"},
+		},
+		{
+			name:   "JoinPrefix",
+			policy: JoinPrefix,
+			want:   map[string]any{"uid": "10001", "db.sku": "a", "branch_1.sku": "b", "branch_1.cache": "hit"},
+		},
+		{
+			name:   "JoinCollect",
+			policy: JoinCollect,
+			want:   map[string]any{"uid": "10001", "sku": []any{"a", "b"}, "cache": "hit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext(context.Background())
+			AddField(ctx, String("uid", "10001"))
+
+			children := []context.Context{ForkNamed(ctx, "db"), Fork(ctx)}
+			var wg sync.WaitGroup
+			for i, child := range children {
+				wg.Add(1)
+				go func(i int, child context.Context) {
+					defer wg.Done()
+					AddField(child, String("sku", string(rune('a'+i))))
+					if i == 1 {
+						AddField(child, String("cache", "hit"))
+					}
+				}(i, child)
+			}
+			wg.Wait()
+
+			if _, ok := FindField(ctx, "sku"); ok {
+				t.Fatalf("Fork() child writes leaked into parent before Join")
+			}
+			JoinWith(ctx, tt.policy, children...)
+
+			for key, want := range tt.want {
+				f, ok := FindField(ctx, key)
+				if !ok {
+					t.Errorf("JoinWith() missing key %q", key)
+					continue
+				}
+				got := fieldValue(f)
+				if arr, ok := f.Interface.(anyArray); ok {
+					got = []any(arr)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("JoinWith() key %q = %v, want = %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestJoin_Twice(t *testing.T) {
+	ctx := NewContext(context.Background())
+	child := Fork(ctx)
+	AddField(child, String("sku", "a"))
+	Join(ctx, child)
+	AddField(ctx, String("sku", "parent"))
+	Join(ctx, child)
+	AddField(child, String("cache", "hit"))
+	Join(ctx, child, child)
+
+	want := map[string]any{"sku": "parent", "cache": "hit"}
+	for key, v := range want {
+		if f, ok := FindField(ctx, key); !ok || fieldValue(f) != v {
+			t.Errorf("Join() twice key %q = %v, want = %v", key, fieldValue(f), v)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package logit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Get 读取字段的值并断言为 T，与 FindField 一样按输出时的合并顺序查找：
+// 元数据字段 → 普通字段 → 不低于指定级别的字段（按级别从低到高）→ 级别字段（按级别从低到高），
+// 命中第一个同名字段后不再继续查找，与默认冲突策略下 Flush 汇总日志中输出的值一致；
+// 单条日志只合并对应级别的字段，同名字段分布在多个级别时输出的值可能不同。字段不存在或类型不是 T 时返回 false。
+//
+// 字段值按写入时的类型还原，例如 zap.Int 写入的值为 int64，zap.Int32 写入的值为 int32，
+// zap.Time 写入的值为 time.Time，zap.Error 写入的值为 error，其他对象类字段返回原始对象。
+// 延迟计算的字段在读取时计算。
+func Get[T any](ctx context.Context, key string) (T, bool) {
+	var zero T
+	f, ok := getField(ctx, key)
+	if !ok {
+		return zero, false
+	}
+	v, ok := fieldNativeValue(f).(T)
+	return v, ok
+}
+
+// GetString 读取字符串字段
+func GetString(ctx context.Context, key string) (string, bool) {
+	return Get[string](ctx, key)
+}
+
+// GetInt64 读取整数字段，兼容各种位宽的有符号和无符号整数，超出 int64 范围时返回 false
+func GetInt64(ctx context.Context, key string) (int64, bool) {
+	f, ok := getField(ctx, key)
+	if !ok {
+		return 0, false
+	}
+	switch v := fieldNativeValue(f).(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// GetDuration 读取耗时字段
+func GetDuration(ctx context.Context, key string) (time.Duration, bool) {
+	return Get[time.Duration](ctx, key)
+}
+
+// GetTime 读取时间字段
+func GetTime(ctx context.Context, key string) (time.Time, bool) {
+	return Get[time.Time](ctx, key)
+}
+
+// GetBool 读取布尔字段
+func GetBool(ctx context.Context, key string) (bool, bool) {
+	return Get[bool](ctx, key)
+}
+
+// getField 按 Get 的顺序查找字段
+func getField(ctx context.Context, key string) (zap.Field, bool) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return zap.Field{}, false
+	}
+
+	buf.mu.RLock()
+	defer buf.mu.RUnlock()
+
+	return buf.find(key)
+}
+
+// fieldNativeValue 按字段类型还原写入时的 Go 值，与 fieldValue 不同，不经过编码器转换
+func fieldNativeValue(f zap.Field) any {
+	if isLazy(f) {
+		f = f.Interface.(*lazyField).resolve()
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String
+	case zapcore.BoolType:
+		return f.Integer == 1
+	case zapcore.Int64Type:
+		return f.Integer
+	case zapcore.Int32Type:
+		return int32(f.Integer)
+	case zapcore.Int16Type:
+		return int16(f.Integer)
+	case zapcore.Int8Type:
+		return int8(f.Integer)
+	case zapcore.Uint64Type:
+		return uint64(f.Integer)
+	case zapcore.Uint32Type:
+		return uint32(f.Integer)
+	case zapcore.Uint16Type:
+		return uint16(f.Integer)
+	case zapcore.Uint8Type:
+		return uint8(f.Integer)
+	case zapcore.UintptrType:
+		return uintptr(f.Integer)
+	case zapcore.Float64Type:
+		return math.Float64frombits(uint64(f.Integer))
+	case zapcore.Float32Type:
+		return math.Float32frombits(uint32(f.Integer))
+	case zapcore.DurationType:
+		return time.Duration(f.Integer)
+	case zapcore.TimeType:
+		if loc, ok := f.Interface.(*time.Location); ok {
+			return time.Unix(0, f.Integer).In(loc)
+		}
+		return time.Unix(0, f.Integer)
+	case zapcore.SkipType:
+		return nil
+	default:
+		// TimeFullType、ErrorType、ByteStringType、BinaryType、ReflectType 以及各种 Marshaler 类型，值保存在 Interface 中
+		return f.Interface
+	}
+}
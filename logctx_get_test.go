@@ -0,0 +1,80 @@
+package logit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestGet(t *testing.T) {
+	now := time.Date(2025, 1, 8, 12, 22, 51, 0, time.UTC)
+	ctx := NewContext(context.Background())
+	AddMetaFields(ctx, String("uid", "meta"), String("trace_id", "abc"))
+	AddDebug(ctx, String("uid", "debug"))
+	AddField(ctx, String("uid", "normal"))
+	AddField(ctx, Int("status", 200))
+	AddField(ctx, zap.Int32("retry", 3))
+	AddField(ctx, zap.Uint64("big", 1<<63))
+	AddField(ctx, zap.Duration("elapsed", time.Second))
+	AddField(ctx, zap.Time("start", now))
+	AddField(ctx, zap.Bool("cached", true))
+	AddField(ctx, zap.Error(errBoom))
+	AddField(ctx, zap.Float64("ratio", 0.5))
+	AddLazy(ctx, "lazy", func() zap.Field { return String("", "computed") })
+	AddLevelFields(ctx, zap.WarnLevel, String("slow", "yes"))
+
+	// 与日志中输出的值一致，元数据优先
+	logger, out := newTestLogger(zap.InfoLevel)
+	logger.Info(ctx, "get")
+	if got, ok := GetString(ctx, "uid"); !ok || got != "meta" || decodeLines(t, out)[0]["uid"] != got {
+		t.Errorf("GetString(uid) = %q, %v, want meta field as logged: %s", got, ok, out.String())
+	}
+	if f, ok := FindField(ctx, "uid"); !ok || f.String != "meta" {
+		t.Errorf("FindField(uid) = %q, %v, want the same field as GetString", f.String, ok)
+	}
+	if got, ok := GetString(ctx, "trace_id"); !ok || got != "abc" {
+		t.Errorf("GetString(trace_id) = %q, %v", got, ok)
+	}
+	if got, ok := GetString(ctx, "slow"); !ok || got != "yes" {
+		t.Errorf("GetString(slow) = %q, %v", got, ok)
+	}
+	if got, ok := GetString(ctx, "lazy"); !ok || got != "computed" {
+		t.Errorf("GetString(lazy) = %q, %v", got, ok)
+	}
+	if _, ok := GetString(ctx, "status"); ok {
+		t.Errorf("GetString(status) ok, want type mismatch")
+	}
+	if got, ok := GetInt64(ctx, "status"); !ok || got != 200 {
+		t.Errorf("GetInt64(status) = %d, %v", got, ok)
+	}
+	if got, ok := GetInt64(ctx, "retry"); !ok || got != 3 {
+		t.Errorf("GetInt64(retry) = %d, %v", got, ok)
+	}
+	if _, ok := GetInt64(ctx, "big"); ok {
+		t.Errorf("GetInt64(big) ok, want overflow")
+	}
+	if got, ok := GetDuration(ctx, "elapsed"); !ok || got != time.Second {
+		t.Errorf("GetDuration(elapsed) = %v, %v", got, ok)
+	}
+	if got, ok := GetTime(ctx, "start"); !ok || !got.Equal(now) {
+		t.Errorf("GetTime(start) = %v, %v", got, ok)
+	}
+	if got, ok := GetBool(ctx, "cached"); !ok || !got {
+		t.Errorf("GetBool(cached) = %v, %v", got, ok)
+	}
+	if got, ok := Get[error](ctx, "error"); !ok || !errors.Is(got, errBoom) {
+		t.Errorf("Get[error](error) = %v, %v", got, ok)
+	}
+	if got, ok := Get[float64](ctx, "ratio"); !ok || got != 0.5 {
+		t.Errorf("Get[float64](ratio) = %v, %v", got, ok)
+	}
+	if _, ok := Get[string](ctx, "missing"); ok {
+		t.Errorf("Get(missing) ok")
+	}
+	if _, ok := GetString(context.Background(), "uid"); ok {
+		t.Errorf("GetString() without buffer ok")
+	}
+}
@@ -0,0 +1,214 @@
+package logit
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fieldGroup 字段分组，按写入顺序输出为嵌套的 JSON 对象。
+// 分组是不可变的，每次写入都会生成新的分组，避免输出时与写入并发冲突。
+type fieldGroup struct {
+	fields []zap.Field
+}
+
+func (g fieldGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, f := range g.fields {
+		f.AddTo(enc)
+	}
+	return nil
+}
+
+// with 返回写入字段后的新分组，path 非空时写入对应的子分组，同名字段覆盖且位置不变。
+// 子分组的位置已有同名的非分组字段时按 policy 处理，返回检测到的冲突，prefix 为当前分组的完整名称。
+func (g fieldGroup) with(prefix string, path []string, fields []zap.Field, policy ConflictPolicy) (fieldGroup, *FieldConflictError) {
+	next := fieldGroup{fields: slices.Clone(g.fields)}
+	if len(path) == 0 {
+		for _, f := range fields {
+			next.set(next.index(f.Key), f)
+		}
+		return next, nil
+	}
+
+	key := path[0]
+	i := next.index(key)
+	sub, isGroup := fieldGroup{}, i < 0
+	if i >= 0 {
+		sub, isGroup = next.fields[i].Interface.(fieldGroup)
+	}
+	if isGroup {
+		sub, conflict := sub.with(prefix+"."+key, path[1:], fields, policy)
+		next.set(i, zap.Object(key, sub))
+		return next, conflict
+	}
+
+	// 已有同名的非分组字段，按冲突策略处理
+	sub, _ = fieldGroup{}.with(prefix+"."+key, path[1:], fields, policy)
+	incoming := zap.Object(key, sub)
+	conflict := &FieldConflictError{
+		Key:      prefix + "." + key,
+		Existing: next.fields[i],
+		Incoming: incoming,
+	}
+	switch policy {
+	case ConflictKeepFirst:
+		return g, conflict
+	case ConflictMoveToEnd:
+		next.fields = slices.Delete(next.fields, i, i+1)
+		i = -1
+	case ConflictRename:
+		incoming.Key = renameKey(key, func(k string) bool {
+			return next.index(k) >= 0
+		})
+		i = -1
+	}
+	next.set(i, incoming)
+	return next, conflict
+}
+
+// without 返回删除 key 后的新分组，key 支持 "sub.key" 形式
+func (g fieldGroup) without(key string) fieldGroup {
+	if i := g.index(key); i >= 0 {
+		return fieldGroup{fields: slices.Delete(slices.Clone(g.fields), i, i+1)}
+	}
+	root, rest, ok := strings.Cut(key, ".")
+	if !ok {
+		return g
+	}
+	i := g.index(root)
+	if i < 0 {
+		return g
+	}
+	sub, ok := g.fields[i].Interface.(fieldGroup)
+	if !ok {
+		return g
+	}
+	next := fieldGroup{fields: slices.Clone(g.fields)}
+	if sub = sub.without(rest); len(sub.fields) == 0 {
+		next.fields = slices.Delete(next.fields, i, i+1)
+		return next
+	}
+	next.fields[i] = zap.Object(root, sub)
+	return next
+}
+
+// find 查找分组内的字段，key 支持 "sub.key" 形式
+func (g fieldGroup) find(key string) (zap.Field, bool) {
+	if i := g.index(key); i >= 0 {
+		return g.fields[i], true
+	}
+	root, rest, ok := strings.Cut(key, ".")
+	if !ok {
+		return zap.Field{}, false
+	}
+	if i := g.index(root); i >= 0 {
+		if sub, ok := g.fields[i].Interface.(fieldGroup); ok {
+			return sub.find(rest)
+		}
+	}
+	return zap.Field{}, false
+}
+
+func (g fieldGroup) index(key string) int {
+	return slices.IndexFunc(g.fields, func(f zap.Field) bool {
+		return f.Key == key
+	})
+}
+
+// set 覆盖第 i 个字段，i 小于 0 时追加到末尾，仅用于 with 生成的新分组
+func (g *fieldGroup) set(i int, f zap.Field) {
+	if i < 0 {
+		g.fields = append(g.fields, f)
+		return
+	}
+	g.fields[i] = f
+}
+
+// findInFields 查找字段，key 不存在且为 "group.key" 形式时查找分组内的字段
+func findInFields(fields *fieldStore, key string) (zap.Field, bool) {
+	if f, ok := fields.get(key); ok {
+		return f, true
+	}
+	root, rest, ok := strings.Cut(key, ".")
+	if !ok {
+		return zap.Field{}, false
+	}
+	if f, ok := fields.get(root); ok {
+		if group, ok := f.Interface.(fieldGroup); ok {
+			return group.find(rest)
+		}
+	}
+	return zap.Field{}, false
+}
+
+// addGroup 将字段写入指定类别的分组，name 支持 "db.conn" 形式的多级分组，
+// 分组或子分组的位置已有同名的非分组字段时按冲突策略处理，返回检测到的冲突，调用方需持有写锁并已调用 own
+func (b *LogBuffer) addGroup(cat FieldCategory, lvl zapcore.Level, name string, fields []zap.Field) error {
+	path := strings.Split(name, ".")
+	f, ok := b.get(cat, lvl, path[0])
+	if !ok {
+		group, _ := fieldGroup{}.with(path[0], path[1:], fields, b.conflict.policy)
+		b.put(cat, lvl, zap.Object(path[0], group))
+		return nil
+	}
+	group, ok := f.Interface.(fieldGroup)
+	if !ok {
+		group, _ = fieldGroup{}.with(path[0], path[1:], fields, b.conflict.policy)
+		return b.set(cat, lvl, zap.Object(path[0], group))
+	}
+	group, conflict := group.with(path[0], path[1:], fields, b.conflict.policy)
+	b.put(cat, lvl, zap.Object(path[0], group))
+	if conflict == nil {
+		return nil
+	}
+	conflict.ExistingCategory, conflict.IncomingCategory = cat, cat
+	return conflict
+}
+
+// setGroup 加锁写入分组，释放锁后再上报冲突，避免回调中写日志时死锁
+func (b *LogBuffer) setGroup(cat FieldCategory, lvl zapcore.Level, name string, fields []zap.Field) {
+	b.mu.Lock()
+	b.own()
+	err := b.addGroup(cat, lvl, name, fields)
+	cc := b.conflict
+	b.mu.Unlock()
+
+	if err != nil {
+		cc.report([]error{err})
+	}
+}
+
+// AddGroup 将字段写入名为 name 的分组，分组内保持写入顺序，输出为嵌套的 JSON 对象。
+// name 支持 "db.conn" 形式的多级分组，分组内的字段可通过 FindField(ctx, "db.rows") 查找或 RemoveField 删除。
+// 分组的位置已有同名的非分组字段时按容器的冲突策略处理，默认以分组覆盖并上报冲突。
+func AddGroup(ctx context.Context, name string, fields ...zap.Field) {
+	buf := getBuf(ctx)
+	if buf == nil || name == "" {
+		return
+	}
+
+	buf.setGroup(CategoryNormal, zapcore.InfoLevel, name, fields)
+}
+
+// AddLevelGroup 将字段写入指定级别的分组，仅对应级别的日志输出
+func AddLevelGroup(ctx context.Context, lvl zapcore.Level, name string, fields ...zap.Field) {
+	buf := getBuf(ctx)
+	if buf == nil || name == "" {
+		return
+	}
+
+	buf.setGroup(CategoryLevel, lvl, name, fields)
+}
+
+// AddMetaGroup 将字段写入元数据分组，所有级别的日志均输出
+func AddMetaGroup(ctx context.Context, name string, fields ...zap.Field) {
+	buf := getBuf(ctx)
+	if buf == nil || name == "" {
+		return
+	}
+
+	buf.setGroup(CategoryMeta, zapcore.InfoLevel, name, fields)
+}
@@ -0,0 +1,136 @@
+package logit
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAddGroup(t *testing.T) {
+	logger, out := newTestLogger(zap.DebugLevel)
+	ctx := NewContext(context.Background())
+
+	AddGroup(ctx, "db", String("table", "orders"), Int("rows", 1))
+	AddGroup(ctx, "cache", Bool("hit", true))
+	AddGroup(ctx, "db", Int("rows", 3))
+	AddGroup(ctx, "db.conn", String("host", "127.0.0.1"))
+	AddLevelGroup(ctx, zap.ErrorLevel, "rpc", String("code", "E500"))
+
+	if f, ok := FindField(ctx, "db.rows"); !ok || f.Integer != 3 {
+		t.Errorf("FindField(db.rows) = %v, %v, want = 3, true", f.Integer, ok)
+	}
+	if f, ok := FindField(ctx, "db.conn.host"); !ok || f.String != "127.0.0.1" {
+		t.Errorf("FindField(db.conn.host) = %v, %v, want = 127.0.0.1, true", f.String, ok)
+	}
+	if _, ok := FindField(ctx, "rpc.code"); !ok {
+		t.Errorf("FindField(rpc.code) not found")
+	}
+
+	logger.Info(ctx, "grouped")
+	want := `"db":{"table":"orders","rows":3,"conn":{"host":"127.0.0.1"}},"cache":{"hit":true}`
+	if got := out.String(); !strings.Contains(got, want) {
+		t.Errorf("Info() = %s, want contains %s", got, want)
+	}
+
+	RemoveField(ctx, "db.rows")
+	RemoveField(ctx, "cache.hit")
+	if _, ok := FindField(ctx, "db.rows"); ok {
+		t.Errorf("RemoveField(db.rows) still found")
+	}
+	if _, ok := FindField(ctx, "db.table"); !ok {
+		t.Errorf("RemoveField(db.rows) removed db.table")
+	}
+	if _, ok := FindField(ctx, "cache"); ok {
+		t.Errorf("RemoveField(cache.hit) should remove empty group")
+	}
+}
+
+func TestAddGroup_Conflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ConflictPolicy
+		run     func(ctx context.Context)
+		want    string
+		wantKey string
+	}{
+		{
+			name: "default overwrites scalar",
+			run: func(ctx context.Context) {
+				AddField(ctx, String("db", "mysql"))
+				AddGroup(ctx, "db", Int("rows", 1))
+			},
+			want:    `"db":{"rows":1}`,
+			wantKey: "db",
+		},
+		{
+			name:   "keep first",
+			policy: ConflictKeepFirst,
+			run: func(ctx context.Context) {
+				AddField(ctx, String("db", "mysql"))
+				AddGroup(ctx, "db", Int("rows", 1))
+			},
+			want:    `"db":"mysql"}`,
+			wantKey: "db",
+		},
+		{
+			name:   "rename",
+			policy: ConflictRename,
+			run: func(ctx context.Context) {
+				AddField(ctx, String("db", "mysql"))
+				AddGroup(ctx, "db", Int("rows", 1))
+			},
+			want:    `"db":"mysql","db_1":{"rows":1}`,
+			wantKey: "db",
+		},
+		{
+			name:   "nested keep first",
+			policy: ConflictKeepFirst,
+			run: func(ctx context.Context) {
+				AddGroup(ctx, "db", String("conn", "x"))
+				AddGroup(ctx, "db.conn", String("host", "h"))
+			},
+			want:    `"db":{"conn":"x"}`,
+			wantKey: "db.conn",
+		},
+		{
+			name:   "nested rename",
+			policy: ConflictRename,
+			run: func(ctx context.Context) {
+				AddGroup(ctx, "db", String("conn", "x"))
+				AddGroup(ctx, "db.conn", String("host", "h"))
+			},
+			want:    `"db":{"conn":"x","conn_1":{"host":"h"}}`,
+			wantKey: "db.conn",
+		},
+		{
+			name: "no conflict",
+			run: func(ctx context.Context) {
+				AddGroup(ctx, "db", Int("rows", 1))
+				AddGroup(ctx, "db", Int("rows", 2))
+			},
+			want: `"db":{"rows":2}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.InfoLevel)
+			ctx := NewContext(context.Background())
+			SetConflictPolicy(ctx, tt.policy)
+			var gotKey string
+			SetConflictHandler(ctx, func(err error) {
+				gotKey = err.(*FieldConflictError).Key
+			})
+
+			tt.run(ctx)
+			logger.Info(ctx, "grouped")
+			if got := out.String(); !strings.Contains(got, tt.want) {
+				t.Errorf("Info() = %s, want contains %s", got, tt.want)
+			}
+			if gotKey != tt.wantKey {
+				t.Errorf("conflict key = %q, want = %q", gotKey, tt.wantKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package logit
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// bufferedField 容器内的一个字段及其类别
+type bufferedField struct {
+	cat   FieldCategory
+	lvl   zapcore.Level
+	field zap.Field
+}
+
+// list 按 元数据 → 普通字段 → 不低于指定级别的字段 → 级别字段（按级别从低到高）的顺序返回全部字段，调用方需持有锁
+func (b *LogBuffer) list() []bufferedField {
+	fields := make([]bufferedField, 0, b.meta.len()+b.normal.len())
+	for f := range b.meta.all() {
+		fields = append(fields, bufferedField{cat: CategoryMeta, lvl: zapcore.InfoLevel, field: f})
+	}
+	for f := range b.normal.all() {
+		fields = append(fields, bufferedField{cat: CategoryNormal, lvl: zapcore.InfoLevel, field: f})
+	}
+	for _, lvl := range sortedLevels(b.atLeast) {
+		for f := range b.atLeast[lvl].all() {
+			fields = append(fields, bufferedField{cat: CategoryLevelAtLeast, lvl: lvl, field: f})
+		}
+	}
+	for _, lvl := range sortedLevels(b.levels) {
+		for f := range b.levels[lvl].all() {
+			fields = append(fields, bufferedField{cat: CategoryLevel, lvl: lvl, field: f})
+		}
+	}
+	return fields
+}
+
+// Range 按 元数据 → 普通字段 → 不低于指定级别的字段 → 级别字段（按级别从低到高）的顺序遍历容器内的字段，
+// fn 返回 false 时停止遍历。level 仅对级别字段有意义，其余类别为 Info。
+// 遍历的是调用时的快照，fn 内可以安全地读写容器。
+func Range(ctx context.Context, fn func(category FieldCategory, level zapcore.Level, field zap.Field) bool) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.mu.RLock()
+	fields := buf.list()
+	buf.mu.RUnlock()
+
+	for _, f := range fields {
+		if !fn(f.cat, f.lvl, f.field) {
+			return
+		}
+	}
+}
+
+// Keys 按 Range 的顺序返回容器内的全部字段名，同名字段只返回一次
+func Keys(ctx context.Context) []string {
+	var keys []string
+	seen := map[string]struct{}{}
+	Range(ctx, func(_ FieldCategory, _ zapcore.Level, field zap.Field) bool {
+		if _, ok := seen[field.Key]; !ok {
+			seen[field.Key] = struct{}{}
+			keys = append(keys, field.Key)
+		}
+		return true
+	})
+	return keys
+}
+
+// Snapshot 将容器内的字段导出为 key → value，同名字段保留 Range 顺序中先出现的值（元数据优先），
+// 分组等对象字段导出为 map[string]any
+func Snapshot(ctx context.Context) map[string]any {
+	snapshot := map[string]any{}
+	Range(ctx, func(_ FieldCategory, _ zapcore.Level, field zap.Field) bool {
+		if _, ok := snapshot[field.Key]; !ok {
+			snapshot[field.Key] = fieldValue(field)
+		}
+		return true
+	})
+	return snapshot
+}
+
+// Reset 清空容器内的全部字段，保留冲突策略、容量限制等配置，便于复用容器
+func Reset(ctx context.Context) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.meta = &fieldStore{}
+	buf.invalidateMeta()
+	buf.normal = &fieldStore{}
+	buf.levels = map[zapcore.Level]*fieldStore{}
+	buf.atLeast = map[zapcore.Level]*fieldStore{}
+	buf.flushed = map[flushKey]zap.Field{}
+	buf.created = time.Now()
+	buf.events = nil
+	buf.eventsDropped = 0
+	buf.shared = false
+	buf.dropped.Store(0)
+	buf.recomputeSize()
+}
+
+// ResetLevel 清空指定级别的级别字段，包括 AddLevelFieldsAtLeast 以该级别写入的字段
+func ResetLevel(ctx context.Context, lvl zapcore.Level) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.own()
+	delete(buf.levels, lvl)
+	delete(buf.atLeast, lvl)
+	maps.DeleteFunc(buf.flushed, func(k flushKey, _ zap.Field) bool {
+		return k.lvl == lvl && (k.cat == CategoryLevel || k.cat == CategoryLevelAtLeast)
+	})
+	buf.recomputeSize()
+}
@@ -0,0 +1,85 @@
+package logit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newInspectContext() context.Context {
+	ctx := NewContext(context.Background())
+	AddError(ctx, String("err_code", "E500"))
+	AddField(ctx, String("uid", "10001"))
+	AddMetaField(ctx, String("trace_id", "abc"))
+	AddDebug(ctx, String("sql", "select 1"))
+	AddGroup(ctx, "db", Int("rows", 3))
+	return ctx
+}
+
+func TestKeys(t *testing.T) {
+	want := []string{"trace_id", "uid", "db", "sql", "err_code"}
+	if got := Keys(newInspectContext()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want = %v", got, want)
+	}
+}
+
+func TestRange(t *testing.T) {
+	type item struct {
+		cat FieldCategory
+		lvl zapcore.Level
+		key string
+	}
+	var got []item
+	Range(newInspectContext(), func(category FieldCategory, level zapcore.Level, field zap.Field) bool {
+		got = append(got, item{cat: category, lvl: level, key: field.Key})
+		return len(got) < 4
+	})
+	want := []item{
+		{cat: CategoryMeta, lvl: zap.InfoLevel, key: "trace_id"},
+		{cat: CategoryNormal, lvl: zap.InfoLevel, key: "uid"},
+		{cat: CategoryNormal, lvl: zap.InfoLevel, key: "db"},
+		{cat: CategoryLevel, lvl: zap.DebugLevel, key: "sql"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Range() = %v, want = %v", got, want)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	want := map[string]any{
+		"trace_id": "abc",
+		"uid":      "10001",
+		"db":       map[string]any{"rows": int64(3)},
+		"sql":      "select 1",
+		"err_code": "E500",
+	}
+	if got := Snapshot(newInspectContext()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v, want = %v", got, want)
+	}
+}
+
+func TestReset(t *testing.T) {
+	ctx := newInspectContext()
+	ResetLevel(ctx, zap.ErrorLevel)
+	if _, ok := FindField(ctx, "err_code"); ok {
+		t.Errorf("ResetLevel() err_code still found")
+	}
+	if _, ok := FindField(ctx, "sql"); !ok {
+		t.Errorf("ResetLevel() removed debug field sql")
+	}
+
+	Reset(ctx)
+	if keys := Keys(ctx); len(keys) != 0 {
+		t.Errorf("Reset() keys = %v, want empty", keys)
+	}
+}
+
+func TestFindField_Meta(t *testing.T) {
+	ctx := newInspectContext()
+	if f, ok := FindField(ctx, "trace_id"); !ok || f.String != "abc" {
+		t.Errorf("FindField(trace_id) = %v, %v, want = abc, true", f.String, ok)
+	}
+}
@@ -0,0 +1,71 @@
+package logit
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// lazyField 延迟计算的字段，只在日志确实输出时才调用 fn。
+// 以指针保存在字段中，同一次写入的字段比较时视为相同，便于 Flush 判断是否已输出。
+type lazyField struct {
+	key string
+	fn  func() zap.Field
+}
+
+// resolve 计算字段值，返回字段的 key 固定为写入时指定的 key
+func (l *lazyField) resolve() zap.Field {
+	f := l.fn()
+	f.Key = l.key
+	return f
+}
+
+// MarshalLogObject 未经过合并直接编码时（例如 Snapshot）在编码时计算字段值
+func (l *lazyField) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	l.resolve().AddTo(enc)
+	return nil
+}
+
+func (l *lazyField) field() zap.Field {
+	return zap.Field{Key: l.key, Type: zapcore.InlineMarshalerType, Interface: l}
+}
+
+// resolveLazy 原地计算 fields 中延迟计算的字段，保留合并后的 key，ConflictRename 可能已经重命名
+func resolveLazy(fields []zap.Field) {
+	for i, f := range fields {
+		if isLazy(f) {
+			resolved := f.Interface.(*lazyField).resolve()
+			resolved.Key = f.Key
+			fields[i] = resolved
+		}
+	}
+}
+
+// isLazy 判断字段是否为延迟计算的字段
+func isLazy(f zap.Field) bool {
+	_, ok := f.Interface.(*lazyField)
+	return ok && f.Type == zapcore.InlineMarshalerType
+}
+
+// addLazy 写入延迟计算的字段，fn 为 nil 时忽略
+func addLazy(ctx context.Context, cat FieldCategory, lvl zapcore.Level, key string, fn func() zap.Field) {
+	buf := getBuf(ctx)
+	if buf == nil || fn == nil {
+		return
+	}
+
+	buf.setFields(cat, lvl, (&lazyField{key: key, fn: fn}).field())
+}
+
+// AddLazy 增加延迟计算的普通字段，适用于序列化请求体等开销较大的字段。
+// fn 只在日志级别开启、确实输出时调用，每条日志最多调用一次，返回字段的 key 会被替换为 key。
+// fn 在释放日志容器的锁之后调用，可以安全地读写日志容器。
+func AddLazy(ctx context.Context, key string, fn func() zap.Field) {
+	addLazy(ctx, CategoryNormal, zapcore.InfoLevel, key, fn)
+}
+
+// AddLevelLazy 增加延迟计算的级别字段，仅对应级别的日志输出时调用 fn
+func AddLevelLazy(ctx context.Context, lvl zapcore.Level, key string, fn func() zap.Field) {
+	addLazy(ctx, CategoryLevel, lvl, key, fn)
+}
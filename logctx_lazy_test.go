@@ -0,0 +1,138 @@
+package logit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAddLazy(t *testing.T) {
+	tests := []struct {
+		name      string
+		add       func(ctx context.Context, key string, fn func() zap.Field)
+		log       func(l *Logger, ctx context.Context)
+		wantCalls int64
+		wantField bool
+	}{
+		{
+			name:      "normal enabled",
+			add:       AddLazy,
+			log:       func(l *Logger, ctx context.Context) { l.Info(ctx, "lazy") },
+			wantCalls: 1,
+			wantField: true,
+		},
+		{
+			name: "normal disabled",
+			add:  AddLazy,
+			log:  func(l *Logger, ctx context.Context) { l.Debug(ctx, "lazy") },
+		},
+		{
+			name: "level disabled",
+			add: func(ctx context.Context, key string, fn func() zap.Field) {
+				AddLevelLazy(ctx, zap.DebugLevel, key, fn)
+			},
+			log: func(l *Logger, ctx context.Context) { l.Debug(ctx, "lazy") },
+		},
+		{
+			name: "level other",
+			add: func(ctx context.Context, key string, fn func() zap.Field) {
+				AddLevelLazy(ctx, zap.ErrorLevel, key, fn)
+			},
+			log: func(l *Logger, ctx context.Context) { l.Warn(ctx, "lazy") },
+		},
+		{
+			name: "level enabled",
+			add: func(ctx context.Context, key string, fn func() zap.Field) {
+				AddLevelLazy(ctx, zap.ErrorLevel, key, fn)
+			},
+			log:       func(l *Logger, ctx context.Context) { l.Error(ctx, "lazy") },
+			wantCalls: 1,
+			wantField: true,
+		},
+		{
+			name: "begin disabled",
+			add:  AddLazy,
+			log: func(l *Logger, ctx context.Context) {
+				NewWithZap(l.Logger.WithOptions(zap.IncreaseLevel(zap.ErrorLevel))).Begin(ctx, "req")(nil)
+			},
+		},
+		{
+			name: "sampled out",
+			add:  AddLazy,
+			log: func(l *Logger, ctx context.Context) {
+				sampled := l.Logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+					return zapcore.NewSamplerWithOptions(c, time.Hour, 0, 0)
+				}))
+				NewWithZap(sampled).Info(ctx, "lazy")
+			},
+		},
+		{
+			name: "tail discarded",
+			add: func(ctx context.Context, key string, fn func() zap.Field) {
+				AddLevelLazy(ctx, zap.DebugLevel, key, fn)
+			},
+			log: func(l *Logger, ctx context.Context) {
+				EnableTailCapture(ctx, zap.DebugLevel, 8)
+				l.Debug(ctx, "lazy")
+				Discard(ctx)
+			},
+		},
+		{
+			name: "tail committed",
+			add: func(ctx context.Context, key string, fn func() zap.Field) {
+				AddLevelLazy(ctx, zap.DebugLevel, key, fn)
+			},
+			log: func(l *Logger, ctx context.Context) {
+				EnableTailCapture(ctx, zap.DebugLevel, 8)
+				l.Debug(ctx, "lazy")
+				Commit(ctx)
+			},
+			wantCalls: 1,
+			wantField: true,
+		},
+		{
+			name:      "begin enabled",
+			add:       AddLazy,
+			log:       func(l *Logger, ctx context.Context) { l.Begin(ctx, "req")(errors.New("boom")) },
+			wantCalls: 1,
+			wantField: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 两个 core 共用同一条日志，延迟字段也只计算一次
+			a, b := &bytes.Buffer{}, &bytes.Buffer{}
+			core := zapcore.NewTee(
+				zapcore.NewCore(getEncoder(), zapcore.AddSync(a), zap.InfoLevel),
+				zapcore.NewCore(getEncoder(), zapcore.AddSync(b), zap.InfoLevel),
+			)
+			logger := NewWithZap(zap.New(core))
+
+			var calls atomic.Int64
+			ctx := NewContext(context.Background())
+			tt.add(ctx, "body", func() zap.Field {
+				calls.Add(1)
+				// fn 内读写容器不会死锁
+				AddField(ctx, String("touched", "1"))
+				return String("ignored", "payload")
+			})
+			tt.log(logger, ctx)
+
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("lazy calls = %d, want = %d", got, tt.wantCalls)
+			}
+			for _, out := range []*bytes.Buffer{a, b} {
+				if got := strings.Contains(out.String(), `"body":"payload"`); got != tt.wantField {
+					t.Errorf("output has body = %v, want = %v: %s", got, tt.wantField, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package logit
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AddLevelFieldsAtLeast 增加不低于指定级别时输出的字段，
+// 例如 AddLevelFieldsAtLeast(ctx, zap.WarnLevel, ...) 的字段会出现在 Warn、Error 等更高级别的日志中
+func AddLevelFieldsAtLeast(ctx context.Context, lvl zapcore.Level, fields ...zap.Field) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.setFields(CategoryLevelAtLeast, lvl, fields...)
+}
+
+// WithNormalFieldsLevel 设置普通字段输出的最低级别，默认为 Info，即 Info 及以上级别的日志都会输出普通字段
+func WithNormalFieldsLevel(lvl zapcore.Level) LoggerOption {
+	return func(l *Logger) {
+		l.merge.normalLevel = lvl
+	}
+}
+
+// SetNormalFieldsLevel 设置上下文中普通字段输出的最低级别，优先于 Logger 的配置
+func SetNormalFieldsLevel(ctx context.Context, lvl zapcore.Level) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.normalLevel = lvl
+	buf.hasNormalLevel = true
+}
+
+// WithLevel 为当前请求单独设置日志级别，上下文中没有日志容器时新建一个。
+// 级别不低于 lvl 的日志即使低于 Logger 配置的级别（包括按名称设置的级别）也会输出，适用于只为单个请求开启 Debug 日志。
+// Fork、Detach 派生的上下文继承该设置。通过 NewWithZap 包装的 logger 不能低于原 core 自身的级别。
+func WithLevel(ctx context.Context, lvl zapcore.Level) context.Context {
+	ctx = WithContext(ctx)
+	buf := getBuf(ctx)
+	if buf == nil {
+		return ctx
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.setControl(func(c *outputControl) {
+		c.level, c.hasLevel = lvl, true
+	})
+	return ctx
+}
+
+// outputControl 输出日志时需要的上下文配置，保存到容器后不再修改
+type outputControl struct {
+	tail *tailBuffer
+	// level 通过 WithLevel 设置的级别，hasLevel 为 false 时未设置
+	level    zapcore.Level
+	hasLevel bool
+}
+
+// controlOf 读取上下文中影响日志输出的配置，不需要加锁
+func controlOf(ctx context.Context) outputControl {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return outputControl{}
+	}
+	return buf.control()
+}
+
+// control 返回容器的输出配置，未设置时返回零值
+func (b *LogBuffer) control() outputControl {
+	if c := b.ctl.Load(); c != nil {
+		return *c
+	}
+	return outputControl{}
+}
+
+// setControl 修改容器的输出配置，调用方需持有写锁，保证并发修改不会丢失
+func (b *LogBuffer) setControl(fn func(c *outputControl)) {
+	c := b.control()
+	fn(&c)
+	b.ctl.Store(&c)
+}
+
+// forces 判断是否需要忽略 Logger 配置的级别输出该级别的日志
+func (c outputControl) forces(lvl zapcore.Level) bool {
+	return c.hasLevel && lvl >= c.level
+}
@@ -0,0 +1,188 @@
+package logit
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNormalFieldsLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []LoggerOption
+		ctxLevel *zapcore.Level
+		want     map[zapcore.Level]bool
+	}{
+		{
+			name: "default",
+			want: map[zapcore.Level]bool{zap.DebugLevel: false, zap.InfoLevel: true, zap.WarnLevel: true, zap.ErrorLevel: true},
+		},
+		{
+			name: "logger_warn",
+			opts: []LoggerOption{WithNormalFieldsLevel(zap.WarnLevel)},
+			want: map[zapcore.Level]bool{zap.DebugLevel: false, zap.InfoLevel: false, zap.WarnLevel: true, zap.ErrorLevel: true},
+		},
+		{
+			name:     "context_debug",
+			opts:     []LoggerOption{WithNormalFieldsLevel(zap.WarnLevel)},
+			ctxLevel: func() *zapcore.Level { l := zap.DebugLevel; return &l }(),
+			want:     map[zapcore.Level]bool{zap.DebugLevel: true, zap.InfoLevel: true, zap.WarnLevel: true, zap.ErrorLevel: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.DebugLevel)
+			logger = logger.WithLoggerOptions(tt.opts...)
+			ctx := NewContext(context.Background())
+			if tt.ctxLevel != nil {
+				SetNormalFieldsLevel(ctx, *tt.ctxLevel)
+			}
+			AddField(ctx, String("uid", "10001"))
+
+			for lvl, want := range tt.want {
+				out.Reset()
+				logger.Output(ctx, lvl, "normal")
+				if got := strings.Contains(out.String(), `"uid"`); got != want {
+					t.Errorf("Output(%s) has uid = %v, want = %v", lvl, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAddLevelFieldsAtLeast(t *testing.T) {
+	logger, out := newTestLogger(zap.DebugLevel)
+	slogger := slog.New(NewZapHandler(logger))
+	ctx := NewContext(context.Background())
+	AddLevelFieldsAtLeast(ctx, zap.WarnLevel, String("slow_sql", "select 1"))
+
+	for lvl, want := range map[zapcore.Level]bool{zap.InfoLevel: false, zap.WarnLevel: true, zap.ErrorLevel: true} {
+		out.Reset()
+		logger.Output(ctx, lvl, "at least")
+		if got := strings.Contains(out.String(), `"slow_sql"`); got != want {
+			t.Errorf("Output(%s) has slow_sql = %v, want = %v", lvl, got, want)
+		}
+	}
+
+	out.Reset()
+	slogger.InfoContext(ctx, "slog info")
+	slogger.ErrorContext(ctx, "slog error")
+	lines := decodeLines(t, out)
+	if _, ok := lines[0]["slow_sql"]; ok {
+		t.Errorf("slog InfoContext() has slow_sql: %v", lines[0])
+	}
+	if _, ok := lines[1]["slow_sql"]; !ok {
+		t.Errorf("slog ErrorContext() missing slow_sql: %v", lines[1])
+	}
+}
+
+func TestAddLevelFieldsAtLeast_Order(t *testing.T) {
+	logger, out := newTestLogger(zap.DebugLevel)
+	ctx := NewContext(context.Background())
+	AddWarn(ctx, String("slow", "level"))
+	AddLevelFieldsAtLeast(ctx, zap.WarnLevel, String("slow", "atleast"))
+
+	logger.Warn(ctx, "output")
+	logger.Flush(ctx, WithFlushLevel(zap.WarnLevel))
+	lines := decodeLines(t, out)
+	if len(lines) != 2 {
+		t.Fatalf("lines = %d, want = 2", len(lines))
+	}
+	if lines[0]["slow"] != lines[1]["slow"] {
+		t.Errorf("Warn() slow = %v, Flush() slow = %v, want the same value", lines[0]["slow"], lines[1]["slow"])
+	}
+}
+
+func TestWithLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		// ctxLevel 为空时不调用 WithLevel
+		ctxLevel string
+		spec     string
+		run      func(ctx context.Context, logger *Logger)
+		wantMsgs []string
+	}{
+		{
+			name: "not set",
+			run: func(ctx context.Context, logger *Logger) {
+				logger.Debug(ctx, "debug")
+				logger.Warn(ctx, "warn")
+			},
+			wantMsgs: []string{"warn"},
+		},
+		{
+			name:     "debug",
+			ctxLevel: "debug",
+			run: func(ctx context.Context, logger *Logger) {
+				logger.Debug(ctx, "debug")
+				logger.Info(ctx, "info")
+			},
+			wantMsgs: []string{"debug", "info"},
+		},
+		{
+			name:     "info",
+			ctxLevel: "info",
+			run: func(ctx context.Context, logger *Logger) {
+				logger.Debug(ctx, "debug")
+				logger.Info(ctx, "info")
+			},
+			wantMsgs: []string{"info"},
+		},
+		{
+			name:     "named",
+			ctxLevel: "debug",
+			spec:     "warn,db=error",
+			run: func(ctx context.Context, logger *Logger) {
+				logger.Named("db").Debug(ctx, "db")
+			},
+			wantMsgs: []string{"db"},
+		},
+		{
+			name:     "fork and begin",
+			ctxLevel: "debug",
+			run: func(ctx context.Context, logger *Logger) {
+				logger.Debug(Fork(ctx), "fork")
+				logger.Begin(ctx, "request")(nil)
+			},
+			wantMsgs: []string{"fork", "request"},
+		},
+		{
+			name:     "slog",
+			ctxLevel: "debug",
+			run: func(ctx context.Context, logger *Logger) {
+				slog.New(NewZapHandler(logger)).DebugContext(ctx, "slog")
+			},
+			wantMsgs: []string{"slog"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.DebugLevel)
+			logger.SetLevel(zap.WarnLevel)
+			if tt.spec != "" {
+				if err := logger.SetLevelSpec(tt.spec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := NewContext(context.Background())
+			if tt.ctxLevel != "" {
+				ctx = WithLevel(ctx, ParseLevel(tt.ctxLevel))
+			}
+
+			tt.run(ctx, logger)
+
+			var msgs []string
+			for _, line := range decodeLines(t, out) {
+				msgs = append(msgs, line["msg"].(string))
+			}
+			if !slices.Equal(msgs, tt.wantMsgs) {
+				t.Errorf("msgs = %v, want = %v", msgs, tt.wantMsgs)
+			}
+		})
+	}
+}
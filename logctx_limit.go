@@ -0,0 +1,274 @@
+package logit
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DroppedFieldsKey 因容量限制被丢弃的字段数，在下一条日志中输出
+const DroppedFieldsKey = "logit_dropped_fields"
+
+// LimitPolicy 超出容量限制时的处理策略
+type LimitPolicy int
+
+const (
+	// LimitReject 拒绝写入新的字段，已有的字段不受影响
+	LimitReject LimitPolicy = iota
+	// LimitEvictOldest 淘汰同一类别中最早写入的字段，为新字段腾出空间
+	LimitEvictOldest
+)
+
+// BufferLimits 日志容器的容量限制，零值表示不限制
+type BufferLimits struct {
+	// MaxKeys 每个类别最多保存的字段数，级别字段按级别分别计算
+	MaxKeys int
+	// MaxValueBytes 字段值的最大字节数，字符串和二进制值超出部分截断，对象、数组等结构化的值
+	// 按 JSON 编码后超出时以截断后的 JSON 字符串保存，分组成员和追加列表中的值逐个截断
+	MaxValueBytes int
+	// MaxTotalBytes 容器内全部字段和事件的估算总大小
+	MaxTotalBytes int
+	// Policy 超出 MaxKeys 或 MaxTotalBytes 时的处理策略
+	Policy LimitPolicy
+}
+
+// DefaultBufferLimits 新建日志容器时使用的容量限制，默认不限制，应在初始化阶段设置
+var DefaultBufferLimits BufferLimits
+
+func (l BufferLimits) enabled() bool {
+	return l.MaxKeys > 0 || l.MaxValueBytes > 0 || l.MaxTotalBytes > 0
+}
+
+// SetBufferLimits 设置上下文日志容器的容量限制，只作用于之后写入的字段
+func SetBufferLimits(ctx context.Context, limits BufferLimits) {
+	buf := getBuf(ctx)
+	if buf == nil {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.limits = limits
+	buf.recomputeSize()
+}
+
+// admit 按容量限制检查待写入的字段，返回截断后的字段以及是否允许写入，调用方需持有写锁并已调用 own
+func (b *LogBuffer) admit(cat FieldCategory, lvl zapcore.Level, f zap.Field) (zap.Field, bool) {
+	f = truncateField(f, b.limits.MaxValueBytes)
+
+	old, exists := b.get(cat, lvl, f.Key)
+	if !exists && b.limits.MaxKeys > 0 {
+		for b.store(cat, lvl).len() >= b.limits.MaxKeys {
+			if b.limits.Policy != LimitEvictOldest || !b.evictOldest(cat, lvl, f.Key) {
+				b.dropped.Add(1)
+				return f, false
+			}
+		}
+	}
+
+	if b.limits.MaxTotalBytes <= 0 {
+		return f, true
+	}
+	delta := fieldSize(f)
+	if exists {
+		delta -= fieldSize(old)
+	}
+	for b.size+delta > b.limits.MaxTotalBytes {
+		if b.limits.Policy != LimitEvictOldest || !b.evictOldest(cat, lvl, f.Key) {
+			b.dropped.Add(1)
+			return f, false
+		}
+	}
+	b.size += delta
+	return f, true
+}
+
+// evictOldest 淘汰同一类别中最早写入的字段（except 除外），没有可淘汰的字段时返回 false
+func (b *LogBuffer) evictOldest(cat FieldCategory, lvl zapcore.Level, except string) bool {
+	for f := range b.store(cat, lvl).all() {
+		if f.Key != except {
+			b.delete(cat, lvl, f.Key)
+			b.dropped.Add(1)
+			return true
+		}
+	}
+	return false
+}
+
+// recomputeSize 重新计算字段的估算总大小，调用方需持有写锁
+func (b *LogBuffer) recomputeSize() {
+	b.size = 0
+	if b.limits.MaxTotalBytes <= 0 {
+		return
+	}
+	for f := range b.meta.all() {
+		b.size += fieldSize(f)
+	}
+	for f := range b.normal.all() {
+		b.size += fieldSize(f)
+	}
+	for _, s := range b.levels {
+		for f := range s.all() {
+			b.size += fieldSize(f)
+		}
+	}
+	for _, s := range b.atLeast {
+		for f := range s.all() {
+			b.size += fieldSize(f)
+		}
+	}
+	for _, e := range b.events {
+		b.size += e.size()
+	}
+}
+
+// truncateField 将字段值截断到 max 字节以内：字符串按 UTF-8 字符边界截断，二进制值直接截断；
+// 分组成员和追加列表中的值逐个截断；对象、数组等结构化的值按 JSON 编码后超出时，以截断后的 JSON 字符串保存
+func truncateField(f zap.Field, max int) zap.Field {
+	if max <= 0 {
+		return f
+	}
+	switch v := f.Interface.(type) {
+	case fieldGroup:
+		f.Interface = v.truncate(max)
+		return f
+	case appendList:
+		f.Interface = v.truncate(max)
+		return f
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = truncateString(f.String, max)
+	case zapcore.BinaryType, zapcore.ByteStringType:
+		if b, ok := f.Interface.([]byte); ok && len(b) > max {
+			f.Interface = b[:max]
+		}
+	default:
+		if isStructured(f) {
+			if s := encodeValue(f); len(s) > max {
+				return zap.String(f.Key, truncateString(s, max))
+			}
+		}
+	}
+	return f
+}
+
+// truncateString 按 UTF-8 字符边界将字符串截断到 max 字节以内
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// truncate 返回成员值截断后的分组，没有需要截断的成员时返回原分组
+func (g fieldGroup) truncate(max int) fieldGroup {
+	var next []zap.Field
+	for i, f := range g.fields {
+		if t := truncateField(f, max); !fieldEqual(t, f) {
+			if next == nil {
+				next = slices.Clone(g.fields)
+			}
+			next[i] = t
+		}
+	}
+	if next == nil {
+		return g
+	}
+	return fieldGroup{fields: next}
+}
+
+// truncate 返回值截断后的列表，没有需要截断的值时返回原列表
+func (l appendList) truncate(max int) appendList {
+	var next []any
+	for i, v := range l.values {
+		f := zap.Any("", v)
+		t := truncateField(f, max)
+		if fieldEqual(t, f) {
+			continue
+		}
+		if next == nil {
+			next = slices.Clone(l.values)
+		}
+		if t.Type == zapcore.StringType {
+			next[i] = t.String
+		} else {
+			next[i] = t.Interface
+		}
+	}
+	if next == nil {
+		return l
+	}
+	l.values = next
+	return l
+}
+
+// isStructured 判断字段值是否为对象、数组等需要编码后才能得知大小的值，
+// 延迟计算的字段和计时器等容器内部使用的值除外
+func isStructured(f zap.Field) bool {
+	switch f.Interface.(type) {
+	case *lazyField, timerStat, accumulator:
+		return false
+	}
+	switch f.Type {
+	case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType,
+		zapcore.ReflectType, zapcore.StringerType:
+		return true
+	default:
+		return false
+	}
+}
+
+// valueEncoder 编码结构化字段值使用的编码器，EncodeEntry 不修改编码器本身，可以并发使用
+var valueEncoder = zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+
+// encodeValue 返回字段值按 JSON 编码后的结果，内联对象返回其全部成员
+func encodeValue(f zap.Field) string {
+	f.Key = ""
+	buf, err := valueEncoder.EncodeEntry(zapcore.Entry{}, []zap.Field{f})
+	if err != nil {
+		return ""
+	}
+	defer buf.Free()
+	// 编码结果为 {"":value}\n，内联对象为 {members}\n
+	s := strings.TrimSuffix(strings.TrimSuffix(buf.String(), zapcore.DefaultLineEnding), "}")
+	s = strings.TrimPrefix(s, "{")
+	if f.Type != zapcore.InlineMarshalerType {
+		s = strings.TrimPrefix(s, `"":`)
+	}
+	return s
+}
+
+// fieldSize 估算字段占用的字节数，结构化的值按 JSON 编码后的长度计算
+func fieldSize(f zap.Field) int {
+	size := len(f.Key) + 8
+	switch v := f.Interface.(type) {
+	case nil, accumulator:
+	case []byte:
+		size += len(v)
+	case fieldGroup:
+		for _, sub := range v.fields {
+			size += fieldSize(sub)
+		}
+	case appendList:
+		for _, item := range v.values {
+			size += fieldSize(zap.Any("", item))
+		}
+	default:
+		if isStructured(f) {
+			size += len(encodeValue(f))
+		} else {
+			size += 32
+		}
+	}
+	return size + len(f.String)
+}
@@ -0,0 +1,123 @@
+package logit
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSetBufferLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  BufferLimits
+		prepare func(ctx context.Context)
+		want    []string
+		absent  []string
+	}{
+		{
+			name:   "max_keys_reject",
+			limits: BufferLimits{MaxKeys: 2},
+			prepare: func(ctx context.Context) {
+				AddField(ctx, Int("a", 1))
+				AddField(ctx, Int("b", 2))
+				AddField(ctx, Int("c", 3))
+				AddField(ctx, Int("a", 4))
+			},
+			want:   []string{`"a":4,"b":2`, `"logit_dropped_fields":1`},
+			absent: []string{`"c"`},
+		},
+		{
+			name:   "max_keys_evict",
+			limits: BufferLimits{MaxKeys: 2, Policy: LimitEvictOldest},
+			prepare: func(ctx context.Context) {
+				AddField(ctx, Int("a", 1))
+				AddField(ctx, Int("b", 2))
+				AddField(ctx, Int("c", 3))
+			},
+			want:   []string{`"b":2,"c":3`, `"logit_dropped_fields":1`},
+			absent: []string{`"a"`},
+		},
+		{
+			name:   "max_value_bytes",
+			limits: BufferLimits{MaxValueBytes: 4},
+			prepare: func(ctx context.Context) {
+				AddField(ctx, String("body", "abcdefgh"))
+				AddField(ctx, String("name", "中文字段"))
+			},
+			want:   []string{`"body":"abcd"`, `"name":"中"`},
+			absent: []string{DroppedFieldsKey},
+		},
+		{
+			name:   "max_total_bytes",
+			limits: BufferLimits{MaxTotalBytes: 64},
+			prepare: func(ctx context.Context) {
+				for i := 0; i < 10; i++ {
+					AddField(ctx, String(strings.Repeat("k", i+1), "0123456789"))
+				}
+			},
+			want:   []string{`"k":"0123456789"`, `"logit_dropped_fields":7`},
+			absent: []string{`"kkkk"`},
+		},
+		{
+			name:   "max_value_bytes_structured",
+			limits: BufferLimits{MaxValueBytes: 16},
+			prepare: func(ctx context.Context) {
+				AddField(ctx, zap.Any("skus", slices.Repeat([]string{"0123456789"}, 100)))
+				AddField(ctx, zap.Ints("ids", []int{1, 2, 3}))
+				AddField(ctx, zap.Reflect("obj", map[string]string{"body": strings.Repeat("x", 100)}))
+			},
+			want:   []string{`"skus":"[\"0123456789\",\"0"`, `"ids":[1,2,3]`, `"obj":"{\"body\":\"xxxxxxx"}`},
+			absent: []string{DroppedFieldsKey},
+		},
+		{
+			name:   "max_value_bytes_nested",
+			limits: BufferLimits{MaxValueBytes: 4},
+			prepare: func(ctx context.Context) {
+				AddGroup(ctx, "db", String("sql", "select 1"), Int("rows", 3))
+				Append(ctx, "tags", "abcdefgh", 12345678)
+			},
+			want: []string{`"db":{"sql":"sele","rows":3}`, `"tags":["abcd",12345678]`},
+		},
+		{
+			name:   "max_total_bytes_structured",
+			limits: BufferLimits{MaxTotalBytes: 200},
+			prepare: func(ctx context.Context) {
+				AddField(ctx, String("uid", "10001"))
+				AddField(ctx, zap.Any("skus", slices.Repeat([]string{"0123456789"}, 1000)))
+				AddField(ctx, zap.Ints("ids", slices.Repeat([]int{1}, 1000)))
+			},
+			want:   []string{`"uid":"10001"`, `"logit_dropped_fields":2`},
+			absent: []string{`"skus"`, `"ids"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.DebugLevel)
+			ctx := NewContext(context.Background())
+			SetBufferLimits(ctx, tt.limits)
+			tt.prepare(ctx)
+
+			logger.Info(ctx, "limits")
+			got := out.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Info() = %s, want contains %s", got, want)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(got, absent) {
+					t.Errorf("Info() = %s, want not contains %s", got, absent)
+				}
+			}
+
+			out.Reset()
+			logger.Info(ctx, "limits")
+			if strings.Contains(out.String(), DroppedFieldsKey) {
+				t.Errorf("Info() emitted %s twice: %s", DroppedFieldsKey, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package logit
+
+import (
+	"context"
+	"slices"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxMetaLoggers 每个容器最多缓存的派生 logger 数量，超出时清空重建
+const maxMetaLoggers = 4
+
+// withFields 按 元数据 → 提取器字段 → 普通字段 → 不低于指定级别的字段 → 当前级别字段 → 调用方字段 的顺序合并字段，
+// 普通字段仅在 lvl 不低于 normalLevel 时输出，同名字段按冲突策略处理。
+// 元数据字段未被覆盖或移动时，返回以元数据派生（zap.Logger.With）并缓存的 logger，
+// 合并后的字段不再包含元数据，元数据只在派生时编码一次。
+// 延迟字段尚未计算，确认日志会写入后需调用 m.resolve，返回的字段来自对象池，写完日志后需调用 m.release 归还。
+func withFields(ctx context.Context, base *zap.Logger, lvl zapcore.Level, mo mergeOptions, fields ...zap.Field) (logger *zap.Logger, merged []zap.Field, m *fieldMerger) {
+	extracted := mo.extract(ctx)
+	buf := getBuf(ctx)
+	if buf == nil {
+		merged, m = mergeExtracted(mo, extracted, fields)
+		return base, merged, m
+	}
+	buf.mu.RLock()
+	m, mo = buf.merge(lvl, mo, extracted, fields)
+	logger, merged = base, m.fields
+	if n := m.pinned; n > 0 && !m.pinnedChanged {
+		logger = buf.metaLogger(base, m.fields[:n])
+		merged = m.fields[n:]
+	}
+	buf.mu.RUnlock()
+
+	mo.conflict.report(m.conflicts)
+	return logger, merged, m
+}
+
+// metaLogger 返回以元数据派生的 logger，按 base 缓存，调用方需持有读锁
+func (b *LogBuffer) metaLogger(base *zap.Logger, meta []zap.Field) *zap.Logger {
+	b.metaMu.Lock()
+	defer b.metaMu.Unlock()
+
+	if l, ok := b.metaLoggers[base]; ok {
+		return l
+	}
+	if b.metaLoggers == nil || len(b.metaLoggers) >= maxMetaLoggers {
+		b.metaLoggers = map[*zap.Logger]*zap.Logger{}
+	}
+	l := base.With(slices.Clone(meta)...)
+	b.metaLoggers[base] = l
+	return l
+}
+
+// invalidateMeta 元数据变化后清空派生 logger 缓存，调用方需持有写锁
+func (b *LogBuffer) invalidateMeta() {
+	b.metaLoggers = nil
+}
@@ -0,0 +1,168 @@
+package logit
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogger_MetaCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []LoggerOption
+		prepare func(ctx context.Context)
+		fields  []zap.Field
+		want    map[string]any
+		absent  []string
+	}{
+		{
+			name:   "meta wins",
+			fields: []zap.Field{String("trace_id", "call")},
+			want:   map[string]any{"trace_id": "abc", "host": "h1"},
+		},
+		{
+			name:   "overwrite falls back",
+			opts:   []LoggerOption{WithConflictPolicy(ConflictOverwrite)},
+			fields: []zap.Field{String("trace_id", "call")},
+			want:   map[string]any{"trace_id": "call", "host": "h1"},
+		},
+		{
+			name: "add meta invalidates",
+			prepare: func(ctx context.Context) {
+				AddMetaField(ctx, String("host", "h2"))
+			},
+			want: map[string]any{"trace_id": "abc", "host": "h2"},
+		},
+		{
+			name: "remove meta invalidates",
+			prepare: func(ctx context.Context) {
+				RemoveField(ctx, "host")
+			},
+			want:   map[string]any{"trace_id": "abc"},
+			absent: []string{"host"},
+		},
+		{
+			name: "reset invalidates",
+			prepare: func(ctx context.Context) {
+				Reset(ctx)
+			},
+			absent: []string{"trace_id", "host"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.InfoLevel)
+			logger = logger.WithLoggerOptions(tt.opts...)
+			ctx := NewContext(context.Background())
+			AddMetaFields(ctx, String("trace_id", "abc"), String("host", "h1"))
+
+			// 第一条日志建立缓存
+			logger.Info(ctx, "first")
+			if tt.prepare != nil {
+				tt.prepare(ctx)
+			}
+			out.Reset()
+			logger.Info(ctx, "second", tt.fields...)
+
+			raw := out.String()
+			for _, key := range []string{"trace_id", "host"} {
+				if n := strings.Count(raw, `"`+key+`"`); n > 1 {
+					t.Errorf("Info() %s appears %d times: %s", key, n, raw)
+				}
+			}
+			line := decodeLines(t, out)[0]
+			for k, v := range tt.want {
+				if line[k] != v {
+					t.Errorf("Info() %s = %v, want = %v", k, line[k], v)
+				}
+			}
+			for _, k := range tt.absent {
+				if _, ok := line[k]; ok {
+					t.Errorf("Info() unexpected %s: %v", k, line)
+				}
+			}
+		})
+	}
+}
+
+func TestLogger_MetaCacheReuse(t *testing.T) {
+	logger, _ := newTestLogger(zap.InfoLevel)
+	ctx := NewContext(context.Background())
+	AddMetaField(ctx, String("trace_id", "abc"))
+
+	logger.Info(ctx, "first")
+	buf := getBuf(ctx)
+	cached := buf.metaLoggers[logger.Logger]
+	if cached == nil {
+		t.Fatalf("Info() did not cache meta logger")
+	}
+	logger.Info(ctx, "second")
+	if buf.metaLoggers[logger.Logger] != cached {
+		t.Errorf("Info() rebuilt meta logger without meta change")
+	}
+
+	child := Fork(ctx)
+	AddMetaField(child, String("branch", "a"))
+	logger.Info(ctx, "third")
+	if buf.metaLoggers[logger.Logger] != cached {
+		t.Errorf("Fork() child write invalidated parent meta logger")
+	}
+}
+
+func TestLogger_MetaCacheNamed(t *testing.T) {
+	logger, _ := newTestLogger(zap.InfoLevel)
+	ctx := NewContext(context.Background())
+	AddMetaField(ctx, String("trace_id", "abc"))
+	buf := getBuf(ctx)
+
+	// 每次请求调用 Named 时复用同一个子 logger，元数据缓存不会被反复清空
+	first := logger.Named("db")
+	first.Info(ctx, "first")
+	cached := buf.metaLoggers[first.Logger]
+	for range 2 * maxMetaLoggers {
+		named := logger.Named("db")
+		named.Info(ctx, "again")
+		if named.Logger != first.Logger || buf.metaLoggers[named.Logger] != cached {
+			t.Fatalf("Named() rebuilt the meta logger")
+		}
+	}
+	if logger.Named("db").Named("conn").Logger != first.Named("conn").Logger {
+		t.Errorf("Named() did not reuse the nested child logger")
+	}
+	if got := len(buf.metaLoggers); got != 1 {
+		t.Errorf("meta loggers = %d, want = 1", got)
+	}
+}
+
+// BenchmarkLogger_Meta 对比元数据缓存前后多次输出同一上下文日志的开销
+func BenchmarkLogger_Meta(b *testing.B) {
+	core := zapcore.NewCore(getEncoder(), zapcore.AddSync(io.Discard), zap.DebugLevel)
+	logger := NewWithZap(zap.New(core))
+	ctx := NewContext(context.Background())
+	for i := range 10 {
+		AddMetaField(ctx, String("meta_"+strconv.Itoa(i), "value"))
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			logger.Info(ctx, "bench")
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		buf := getBuf(ctx)
+		b.ReportAllocs()
+		for b.Loop() {
+			buf.mu.RLock()
+			m, _ := buf.merge(zap.InfoLevel, logger.merge, nil, nil)
+			buf.mu.RUnlock()
+			logger.Logger.Info("bench", m.fields...)
+			m.release()
+		}
+	})
+}
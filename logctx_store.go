@@ -0,0 +1,149 @@
+package logit
+
+import (
+	"iter"
+	"slices"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// compactMinSlots 槽位数不少于该值且墓碑超过一半时才压缩，避免字段较少时频繁搬移
+const compactMinSlots = 16
+
+// fieldStore 有序字段存储，字段按首次写入的顺序保存在 slots 中，index 记录 key → 槽位下标。
+// 覆盖写入原地更新，删除只标记墓碑，墓碑超过一半时再压缩，读写均为 O(1)。
+// 零值可以直接使用。
+type fieldStore struct {
+	slots []storeSlot
+	index map[string]int
+	// dead 墓碑数量
+	dead int
+}
+
+type storeSlot struct {
+	field zap.Field
+	dead  bool
+}
+
+// len 返回有效字段数，s 为 nil 时返回 0
+func (s *fieldStore) len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.index)
+}
+
+func (s *fieldStore) get(key string) (zap.Field, bool) {
+	if s == nil {
+		return zap.Field{}, false
+	}
+	if i, ok := s.index[key]; ok {
+		return s.slots[i].field, true
+	}
+	return zap.Field{}, false
+}
+
+// set 写入字段，同名字段覆盖且位置不变
+func (s *fieldStore) set(f zap.Field) {
+	if i, ok := s.index[f.Key]; ok {
+		s.slots[i].field = f
+		return
+	}
+	if s.index == nil {
+		s.index = map[string]int{}
+	}
+	s.index[f.Key] = len(s.slots)
+	s.slots = append(s.slots, storeSlot{field: f})
+}
+
+// delete 删除字段，返回是否存在
+func (s *fieldStore) delete(key string) bool {
+	i, ok := s.index[key]
+	if !ok {
+		return false
+	}
+	delete(s.index, key)
+	s.slots[i] = storeSlot{dead: true}
+	s.dead++
+
+	switch {
+	case len(s.index) == 0:
+		clear(s.slots)
+		s.slots = s.slots[:0]
+		s.dead = 0
+	case len(s.slots) >= compactMinSlots && s.dead*2 > len(s.slots):
+		s.compact()
+	}
+	return true
+}
+
+// compact 原地移除墓碑并重建索引
+func (s *fieldStore) compact() {
+	n := 0
+	for _, slot := range s.slots {
+		if slot.dead {
+			continue
+		}
+		s.slots[n] = slot
+		s.index[slot.field.Key] = n
+		n++
+	}
+	clear(s.slots[n:])
+	s.slots = s.slots[:n]
+	s.dead = 0
+}
+
+// all 按写入顺序遍历有效字段，s 为 nil 时不遍历
+func (s *fieldStore) all() iter.Seq[zap.Field] {
+	return func(yield func(zap.Field) bool) {
+		if s == nil {
+			return
+		}
+		for i := range s.slots {
+			if s.slots[i].dead {
+				continue
+			}
+			if !yield(s.slots[i].field) {
+				return
+			}
+		}
+	}
+}
+
+// clone 返回独立的副本，同时去掉墓碑
+func (s *fieldStore) clone() *fieldStore {
+	if s == nil {
+		return nil
+	}
+	c := &fieldStore{
+		slots: make([]storeSlot, 0, len(s.index)),
+		index: make(map[string]int, len(s.index)),
+	}
+	for f := range s.all() {
+		c.set(f)
+	}
+	return c
+}
+
+// cloneStores 拷贝按级别划分的字段存储
+func cloneStores(m map[zapcore.Level]*fieldStore) map[zapcore.Level]*fieldStore {
+	c := make(map[zapcore.Level]*fieldStore, len(m))
+	for lvl, s := range m {
+		c[lvl] = s.clone()
+	}
+	return c
+}
+
+// sortedLevels 按日志级别从低到高返回，保证输出顺序稳定
+func sortedLevels[V any](m map[zapcore.Level]V) []zapcore.Level {
+	if len(m) == 0 {
+		return nil
+	}
+	levels := make([]zapcore.Level, 0, len(m))
+	for lvl := range m {
+		levels = append(levels, lvl)
+	}
+	slices.Sort(levels)
+	return levels
+}
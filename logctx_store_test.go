@@ -0,0 +1,239 @@
+package logit
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func storeKeys(s *fieldStore) []string {
+	var keys []string
+	for f := range s.all() {
+		keys = append(keys, f.Key)
+	}
+	return keys
+}
+
+func TestFieldStore(t *testing.T) {
+	tests := []struct {
+		name      string
+		run       func(s *fieldStore)
+		wantKeys  []string
+		wantSlots int
+	}{
+		{
+			name: "overwrite keeps position",
+			run: func(s *fieldStore) {
+				s.set(String("a", "1"))
+				s.set(String("b", "2"))
+				s.set(String("a", "3"))
+			},
+			wantKeys:  []string{"a", "b"},
+			wantSlots: 2,
+		},
+		{
+			name: "delete leaves tombstone",
+			run: func(s *fieldStore) {
+				s.set(String("a", "1"))
+				s.set(String("b", "2"))
+				s.set(String("c", "3"))
+				s.delete("b")
+				s.set(String("b", "4"))
+			},
+			wantKeys:  []string{"a", "c", "b"},
+			wantSlots: 4,
+		},
+		{
+			name: "delete all resets slots",
+			run: func(s *fieldStore) {
+				s.set(String("a", "1"))
+				s.delete("a")
+			},
+			wantSlots: 0,
+		},
+		{
+			name: "compact",
+			run: func(s *fieldStore) {
+				for i := range 32 {
+					s.set(Int("k"+strconv.Itoa(i), i))
+				}
+				for i := range 17 {
+					s.delete("k" + strconv.Itoa(i))
+				}
+			},
+			wantKeys: func() []string {
+				var keys []string
+				for i := 17; i < 32; i++ {
+					keys = append(keys, "k"+strconv.Itoa(i))
+				}
+				return keys
+			}(),
+			wantSlots: 15,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fieldStore{}
+			tt.run(s)
+			if got := storeKeys(s); !slices.Equal(got, tt.wantKeys) {
+				t.Errorf("keys = %v, want = %v", got, tt.wantKeys)
+			}
+			if len(s.slots) != tt.wantSlots {
+				t.Errorf("slots = %d, want = %d", len(s.slots), tt.wantSlots)
+			}
+			for i, key := range tt.wantKeys {
+				if _, ok := s.get(key); !ok {
+					t.Errorf("get(%q) not found at %d", key, i)
+				}
+			}
+		})
+	}
+}
+
+var benchKeyCounts = []int{0, 10, 100, 1000}
+
+func newBenchContext(n int) context.Context {
+	ctx := NewContext(context.Background())
+	AddMetaField(ctx, String("trace_id", "abc"))
+	for i := range n {
+		AddField(ctx, Int("key_"+strconv.Itoa(i), i))
+	}
+	return ctx
+}
+
+// BenchmarkWithFields 对比有序存储加对象池的合并与改造前 allFields 的合并在不同字段数下的开销
+func BenchmarkWithFields(b *testing.B) {
+	base := zap.NewNop()
+	for _, n := range benchKeyCounts {
+		b.Run("indexed/keys="+strconv.Itoa(n), func(b *testing.B) {
+			ctx := newBenchContext(n)
+			b.ReportAllocs()
+			for b.Loop() {
+				_, _, m := withFields(ctx, base, zap.InfoLevel, mergeOptions{}, String("msg_id", "1"))
+				m.release()
+			}
+		})
+		b.Run("linear/keys="+strconv.Itoa(n), func(b *testing.B) {
+			buf := newLinearBuffer(n)
+			b.ReportAllocs()
+			for b.Loop() {
+				_ = buf.allFields(zap.InfoLevel, String("msg_id", "1"))
+			}
+		})
+	}
+}
+
+// linearStore 改造前的存储方式：map 保存字段，切片保存顺序，写入和删除需要线性查找
+type linearStore struct {
+	order  []string
+	fields map[string]zap.Field
+}
+
+func newLinearStore() *linearStore {
+	return &linearStore{fields: map[string]zap.Field{}}
+}
+
+func (s *linearStore) set(f zap.Field) {
+	if !slices.Contains(s.order, f.Key) {
+		s.order = append(s.order, f.Key)
+	}
+	s.fields[f.Key] = f
+}
+
+func (s *linearStore) delete(key string) {
+	delete(s.fields, key)
+	if i := slices.Index(s.order, key); i >= 0 {
+		s.order = slices.Delete(s.order, i, i+1)
+	}
+}
+
+// linearBuffer 改造前的日志容器，字段与 newBenchContext 相同
+type linearBuffer struct {
+	mu     sync.RWMutex
+	meta   *linearStore
+	normal *linearStore
+	levels map[zapcore.Level]*linearStore
+}
+
+func newLinearBuffer(n int) *linearBuffer {
+	buf := &linearBuffer{meta: newLinearStore(), normal: newLinearStore(), levels: map[zapcore.Level]*linearStore{}}
+	buf.meta.set(String("trace_id", "abc"))
+	for i := range n {
+		buf.normal.set(Int("key_"+strconv.Itoa(i), i))
+	}
+	return buf
+}
+
+// allFields 改造前的合并方式，每次调用都分配新的切片和去重用的 map
+func (b *linearBuffer) allFields(lvl zapcore.Level, fields ...zap.Field) []zap.Field {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	final := make([]zap.Field, 0)
+	fkv := make(map[string]zap.Field, len(b.normal.fields)+len(b.levels))
+
+	for _, k := range b.meta.order {
+		if f, ok := b.meta.fields[k]; ok {
+			final = append(final, f)
+			fkv[k] = f
+		}
+	}
+	if lvl == zap.InfoLevel {
+		for _, k := range b.normal.order {
+			if f, ok := b.normal.fields[k]; ok {
+				if _, ok := fkv[k]; !ok {
+					final = append(final, f)
+				}
+			}
+		}
+	}
+	if s, ok := b.levels[lvl]; ok {
+		for _, k := range s.order {
+			if _, ok := fkv[k]; !ok {
+				final = append(final, s.fields[k])
+			}
+		}
+	}
+	for _, f := range fields {
+		if _, ok := fkv[f.Key]; !ok {
+			final = append(final, f)
+		}
+	}
+	return final
+}
+
+// BenchmarkFieldStore 对比有序存储与线性查找在已有字段的基础上写入并删除一个字段的开销
+func BenchmarkFieldStore(b *testing.B) {
+	for _, n := range benchKeyCounts {
+		keys := make([]string, n)
+		for i := range keys {
+			keys[i] = "key_" + strconv.Itoa(i)
+		}
+		b.Run("indexed/keys="+strconv.Itoa(n), func(b *testing.B) {
+			s := &fieldStore{}
+			for _, k := range keys {
+				s.set(String(k, "v"))
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				s.set(String("probe", "v"))
+				s.delete("probe")
+			}
+		})
+		b.Run("linear/keys="+strconv.Itoa(n), func(b *testing.B) {
+			s := newLinearStore()
+			for _, k := range keys {
+				s.set(String(k, "v"))
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				s.set(String("probe", "v"))
+				s.delete("probe")
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package logit
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// tailEntry 暂存的一条完整日志，core 为写入时使用的 core（已包含元数据等上下文字段）
+type tailEntry struct {
+	core   zapcore.Core
+	ent    zapcore.Entry
+	fields []zap.Field
+}
+
+// tailBuffer 尾部采样的环形缓冲区，Fork 出的容器共享同一个缓冲区
+type tailBuffer struct {
+	mu sync.Mutex
+	// level 不高于该级别的日志暂存到缓冲区而不是直接写入
+	level   zapcore.Level
+	entries []tailEntry
+	// next 下一条日志写入的位置，full 表示缓冲区已写满，之后覆盖最早的日志
+	next int
+	full bool
+}
+
+// captures 判断指定级别的日志是否暂存，t 为 nil 时返回 false
+func (t *tailBuffer) captures(lvl zapcore.Level) bool {
+	return t != nil && lvl <= t.level
+}
+
+func (t *tailBuffer) add(core zapcore.Core, ent zapcore.Entry, fields []zap.Field) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// fields 来自对象池，需要拷贝
+	t.entries[t.next] = tailEntry{core: core, ent: ent, fields: slices.Clone(fields)}
+	t.next++
+	if t.next == len(t.entries) {
+		t.next = 0
+		t.full = true
+	}
+}
+
+// take 按写入顺序取出全部暂存的日志并清空缓冲区
+func (t *tailBuffer) take() []tailEntry {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var entries []tailEntry
+	if t.full {
+		entries = append(entries, t.entries[t.next:]...)
+	}
+	entries = append(entries, t.entries[:t.next]...)
+	clear(t.entries)
+	t.next = 0
+	t.full = false
+	return entries
+}
+
+// commit 按原始时间戳写入暂存的日志，延迟计算的字段在此时计算
+func (t *tailBuffer) commit() {
+	for _, e := range t.take() {
+		resolveLazy(e.fields)
+		_ = e.core.Write(e.ent, e.fields)
+	}
+}
+
+// wrap 返回将日志写入缓冲区的 logger，保留 logger 的调用位置、名称等配置
+func (t *tailBuffer) wrap(logger *zap.Logger) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &tailCore{Core: core, tail: t}
+	}))
+}
+
+// tailCore 将日志写入尾部采样缓冲区的 core，不受原 core 日志级别的限制
+type tailCore struct {
+	zapcore.Core
+	tail *tailBuffer
+}
+
+func (c *tailCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *tailCore) With(fields []zap.Field) zapcore.Core {
+	return &tailCore{Core: c.Core.With(fields), tail: c.tail}
+}
+
+func (c *tailCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c *tailCore) Write(ent zapcore.Entry, fields []zap.Field) error {
+	c.tail.add(c.Core, ent, fields)
+	return nil
+}
+
+// tailOf 返回上下文的尾部采样缓冲区，未开启时返回 nil
+func tailOf(ctx context.Context) *tailBuffer {
+	return controlOf(ctx).tail
+}
+
+// EnableTailCapture 为上下文开启尾部采样：不高于 level 的日志（通常为 Debug）不直接写入，
+// 而是以完整日志的形式暂存在容量为 size 的环形缓冲区中，超出容量时覆盖最早的日志。
+// 同一上下文输出 Error 及以上级别的日志时自动 Commit，也可以手动调用 Commit 或 Discard。
+// 暂存的日志不受 core 日志级别的限制，Commit 时按原始时间戳写入。
+func EnableTailCapture(ctx context.Context, level zapcore.Level, size int) {
+	buf := getBuf(ctx)
+	if buf == nil || size <= 0 {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.setControl(func(c *outputControl) {
+		c.tail = &tailBuffer{level: level, entries: make([]tailEntry, size)}
+	})
+}
+
+// Commit 写入尾部采样缓冲区中暂存的日志并清空缓冲区，之后的日志继续暂存
+func Commit(ctx context.Context) {
+	tailOf(ctx).commit()
+}
+
+// Discard 丢弃尾部采样缓冲区中暂存的日志，通常在请求成功结束时调用
+func Discard(ctx context.Context) {
+	tailOf(ctx).take()
+}
@@ -0,0 +1,150 @@
+package logit
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestTailCapture(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      func(ctx context.Context, logger *Logger)
+		wantMsgs []string
+	}{
+		{
+			name: "captured until commit",
+			run: func(ctx context.Context, logger *Logger) {
+				logger.Debug(ctx, "d1")
+				logger.Info(ctx, "i1")
+			},
+			wantMsgs: []string{"i1"},
+		},
+		{
+			name: "commit",
+			run: func(ctx context.Context, logger *Logger) {
+				logger.Debug(ctx, "d1")
+				logger.Debug(ctx, "d2")
+				Commit(ctx)
+				logger.Debug(ctx, "d3")
+			},
+			wantMsgs: []string{"d1", "d2"},
+		},
+		{
+			name: "error commits",
+			run: func(ctx context.Context, logger *Logger) {
+				logger.Debug(ctx, "d1")
+				logger.Info(ctx, "i1")
+				logger.Error(ctx, "e1")
+			},
+			wantMsgs: []string{"i1", "d1", "e1"},
+		},
+		{
+			name: "discard",
+			run: func(ctx context.Context, logger *Logger) {
+				logger.Debug(ctx, "d1")
+				Discard(ctx)
+				logger.Debug(ctx, "d2")
+				Commit(ctx)
+			},
+			wantMsgs: []string{"d2"},
+		},
+		{
+			name: "ring keeps latest",
+			run: func(ctx context.Context, logger *Logger) {
+				for _, msg := range []string{"d1", "d2", "d3", "d4"} {
+					logger.Debug(ctx, msg)
+				}
+				Commit(ctx)
+			},
+			wantMsgs: []string{"d2", "d3", "d4"},
+		},
+		{
+			name: "begin error commits",
+			run: func(ctx context.Context, logger *Logger) {
+				finish := logger.Begin(ctx, "request")
+				logger.Debug(ctx, "d1")
+				finish(errors.New("boom"))
+				Commit(ctx)
+			},
+			wantMsgs: []string{"d1", "request"},
+		},
+		{
+			name: "begin success discards",
+			run: func(ctx context.Context, logger *Logger) {
+				finish := logger.Begin(ctx, "request")
+				logger.Debug(ctx, "d1")
+				finish(nil)
+				Commit(ctx)
+			},
+			wantMsgs: []string{"request"},
+		},
+		{
+			name: "fork shares buffer",
+			run: func(ctx context.Context, logger *Logger) {
+				child := Fork(ctx)
+				logger.Debug(child, "d1")
+				logger.Error(ctx, "e1")
+			},
+			wantMsgs: []string{"d1", "e1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.InfoLevel)
+			ctx := NewContext(context.Background())
+			AddMetaField(ctx, String("trace_id", "abc"))
+			EnableTailCapture(ctx, zap.DebugLevel, 3)
+
+			tt.run(ctx, logger)
+
+			var msgs []string
+			for _, line := range decodeLines(t, out) {
+				msgs = append(msgs, line["msg"].(string))
+				if line["trace_id"] != "abc" {
+					t.Errorf("line %v missing meta field", line)
+				}
+			}
+			if !slices.Equal(msgs, tt.wantMsgs) {
+				t.Errorf("msgs = %v, want = %v", msgs, tt.wantMsgs)
+			}
+		})
+	}
+}
+
+func TestTailCapture_KeepsEntry(t *testing.T) {
+	logger, out := newTestLogger(zap.InfoLevel)
+	ctx := NewContext(context.Background())
+	EnableTailCapture(ctx, zap.DebugLevel, 8)
+
+	logger.Debug(ctx, "d1", String("sql", "select 1"))
+	first := tailOf(ctx).entries[0].ent
+	logger.Error(ctx, "e1")
+
+	lines := decodeLines(t, out)
+	if len(lines) != 2 {
+		t.Fatalf("lines = %d, want = 2", len(lines))
+	}
+	line := lines[0]
+	if line["level"] != "debug" || line["sql"] != "select 1" || line["time"] != first.Time.Format(time.DateTime) {
+		t.Errorf("committed line = %v, want original debug entry at %v", line, first.Time)
+	}
+}
+
+func TestTailCapture_Disabled(t *testing.T) {
+	logger, out := newTestLogger(zap.InfoLevel)
+	ctx := NewContext(context.Background())
+	EnableTailCapture(ctx, zapcore.DebugLevel, 0)
+
+	logger.Debug(ctx, "d1")
+	Commit(ctx)
+	Discard(context.Background())
+	if out.Len() != 0 {
+		t.Errorf("output = %s, want empty", out.String())
+	}
+}
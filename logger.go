@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -16,19 +17,43 @@ var (
 	once          sync.Once
 )
 
+// Config 日志配置，可通过 LoadConfig、ConfigFromEnv、RegisterFlags 从 JSON 文件、环境变量和命令行参数读取
 type Config struct {
-	Filename   string
-	MaxSize    int // MB
-	MaxBackups int
-	MaxAge     int // days
-	Compress   bool
-	Level      string // debug, info, warn, error
-	ToStdout   bool
-	Encoder    zapcore.Encoder
+	Filename   string `json:"filename"`
+	MaxSize    int    `json:"max_size"` // MB
+	MaxBackups int    `json:"max_backups"`
+	MaxAge     int    `json:"max_age"` // days
+	Compress   bool   `json:"compress"`
+	Level      string `json:"level"` // debug, info, warn, error，支持 "info,db=debug" 按 logger 名称设置级别，见 ParseLevelSpec
+	ToStdout   bool   `json:"to_stdout"`
+	// Encoder 只能在代码中设置
+	Encoder zapcore.Encoder `json:"-"`
+
+	// Dispatch 按规则分发到多个文件，设置后由 NewWithConfig 使用 NewWithDispatch 构建
+	Dispatch *DispatchConfig `json:"dispatch,omitempty"`
 }
 
 type Logger struct {
 	*zap.Logger
+
+	// level 可运行时调整的日志级别
+	level *levelControl
+	// named Named 派生的子 Logger 缓存
+	named *namedLoggers
+	// merge 合并上下文字段时的配置
+	merge mergeOptions
+}
+
+// LoggerOption Logger 的可选配置
+type LoggerOption func(*Logger)
+
+// WithLoggerOptions 返回应用了可选配置的 Logger 副本，原 Logger 不受影响
+func (l *Logger) WithLoggerOptions(opts ...LoggerOption) *Logger {
+	c := *l
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &c
 }
 
 // InitLogger 初始化全局日志字段
@@ -39,21 +64,24 @@ func InitLogger(config Config) {
 }
 
 // New 初始化日志对象，默认使用 lumberjack.v2 作为日志切割
-func New(cfg Config) *Logger {
+func New(cfg Config, opts ...LoggerOption) *Logger {
 	writeSyncer := getWriter(cfg)
 	encoder := cfg.Encoder
 	if encoder == nil {
 		encoder = getEncoder()
 	}
 
-	level := ParseLevel(cfg.Level)
+	// 解析失败的部分忽略，与 ParseLevel 一样无法识别的默认级别按 info 处理
+	spec, _ := ParseLevelSpec(cfg.Level)
+	level := newLevelControl(spec)
 
-	core := zapcore.NewCore(encoder, writeSyncer, level)
+	// 级别统一由外层的 levelCore 控制，core 本身不过滤，便于 WithLevel 为单个请求开启更低的级别
+	core := zapcore.NewCore(encoder, writeSyncer, zap.DebugLevel)
 	if cfg.ToStdout {
 		consoleCore := zapcore.NewCore(
 			zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
 			zapcore.AddSync(os.Stdout),
-			level,
+			zap.DebugLevel,
 		)
 		core = zapcore.NewTee(core, consoleCore)
 	}
@@ -64,7 +92,7 @@ func New(cfg Config) *Logger {
 		zap.AddStacktrace(zap.ErrorLevel),
 	)
 
-	return &Logger{l}
+	return (&Logger{Logger: withLevel(l, level), level: level, named: &namedLoggers{}}).WithLoggerOptions(opts...)
 }
 
 func (l *Logger) Debug(ctx context.Context, msg string, fields ...zap.Field) {
@@ -91,25 +119,142 @@ func (l *Logger) Panic(ctx context.Context, msg string, fields ...zap.Field) {
 	l.Output(ctx, zap.PanicLevel, msg, fields...)
 }
 
-// Output 日志刷入磁盘
+// Output 日志刷入磁盘，级别未开启时直接返回，不会合并上下文字段。
+// 上下文中的元数据字段只在首次输出时编码一次，之后复用缓存的 logger。
+// 上下文开启了尾部采样时，低级别的日志暂存到缓冲区，Error 及以上级别的日志输出前先写入暂存的日志。
+// 上下文通过 WithLevel 设置了级别时，不低于该级别的日志忽略 Logger 配置的级别。
 func (l *Logger) Output(ctx context.Context, lvl zapcore.Level, msg string, fields ...zap.Field) {
-	final := allFields(ctx, lvl, fields...)
-	l.Logger.Log(lvl, msg, final...)
+	ctl := controlOf(ctx)
+	capture := ctl.tail.captures(lvl)
+	force := false
+	if !capture && lvl < zap.DPanicLevel && !l.enabled(lvl) {
+		if !ctl.forces(lvl) {
+			return
+		}
+		force = true
+	}
+	if lvl >= zap.ErrorLevel {
+		ctl.tail.commit()
+	}
+	logger, final, m := withFields(ctx, l.Logger, lvl, l.merge, fields...)
+	if force {
+		logger = bypassLevel(logger)
+	}
+	if capture {
+		logger = ctl.tail.wrap(logger)
+	}
+	if ce := logger.Check(lvl, msg); ce != nil {
+		// 暂存到尾部采样缓冲区的日志在 Commit 时才计算延迟字段
+		if !capture {
+			m.resolve()
+		}
+		ce.Write(final...)
+	}
+	m.release()
+}
+
+// Begin 标记一次请求的开始，返回的 finish 函数应在请求结束时调用。
+// finish 会汇总上下文中缓冲的全部字段，附加耗时和错误信息后输出一条日志，
+// 日志级别取 Info、错误（Error）以及 AddLevelFields 指定过的最高级别中的最大值，
+// 为避免触发 panic 或退出进程，最高只提升到 Error。多次调用 finish 只会输出一次。
+// 上下文开启了尾部采样时，级别为 Error 则先写入暂存的日志，否则丢弃暂存的日志。
+func (l *Logger) Begin(ctx context.Context, name string) func(err error) {
+	start := time.Now()
+	var done atomic.Bool
+
+	return func(err error) {
+		if !done.CompareAndSwap(false, true) {
+			return
+		}
+		base := zap.InfoLevel
+		if err != nil {
+			base = zap.ErrorLevel
+		}
+		agg := flushFields(ctx, false, l.merge, zap.Duration("elapsed", time.Since(start)), zap.Error(err))
+		lvl := agg.level(base)
+		if lvl >= zap.ErrorLevel {
+			Commit(ctx)
+		}
+		l.emit(ctx, lvl, name, agg)
+		Discard(ctx)
+	}
+}
+
+// emit 写入汇总的字段并归还对象池，级别开启时才计算延迟字段，写入后才将字段标记为已输出，
+// 保持与 Output 相同的调用栈深度
+func (l *Logger) emit(ctx context.Context, lvl zapcore.Level, msg string, agg aggregation) {
+	logger := l.Logger
+	if !l.enabled(lvl) && controlOf(ctx).forces(lvl) {
+		logger = bypassLevel(logger)
+	}
+	ce := logger.Check(lvl, msg)
+	if ce != nil {
+		agg.resolve()
+		ce.Write(agg.fields...)
+	}
+	agg.done(ce != nil)
+	agg.release()
 }
 
-// Flush 将各个级别的日志统一写入磁盘
-func (l *Logger) Flush(ctx context.Context) {
-	buf := getBuf(ctx)
-	if buf == nil {
+// FlushOption Flush 的可选参数
+type FlushOption func(*flushOptions)
+
+type flushOptions struct {
+	msg      string
+	level    zapcore.Level
+	hasLevel bool
+	reset    bool
+}
+
+// WithFlushMessage 设置汇总日志的消息内容，默认为空
+func WithFlushMessage(msg string) FlushOption {
+	return func(o *flushOptions) {
+		o.msg = msg
+	}
+}
+
+// WithFlushLevel 指定汇总日志的级别，默认取 Info 与已写入级别字段中的最高级别（不超过 Error）
+func WithFlushLevel(lvl zapcore.Level) FlushOption {
+	return func(o *flushOptions) {
+		o.level = lvl
+		o.hasLevel = true
+	}
+}
+
+// WithFlushReset 输出后是否清空容器内的普通字段和级别字段，元数据字段始终保留
+func WithFlushReset(reset bool) FlushOption {
+	return func(o *flushOptions) {
+		o.reset = reset
+	}
+}
+
+// Flush 将容器内的元数据、普通字段、各级别字段（按级别从低到高）和事件时间线汇总为一条日志写入。
+// 写入成功后输出过的字段会被标记，重复调用 Flush 或之后 Begin 的汇总日志不会再次输出未变化的字段，
+// 普通日志不受标记影响。级别未开启时字段保持不变；没有新的普通字段或级别字段时不会输出。
+func (l *Logger) Flush(ctx context.Context, opts ...FlushOption) {
+	if getBuf(ctx) == nil {
 		return
 	}
+	o := flushOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	buf.mu.Lock()
-	defer buf.mu.Unlock()
+	if o.hasLevel && !l.enabled(o.level) && !controlOf(ctx).forces(o.level) {
+		return
+	}
 
-	for lvl := range buf.levelOrder {
-		l.Output(ctx, lvl, "")
+	agg := flushFields(ctx, o.reset, l.merge)
+	if agg.pending == 0 {
+		agg.done(false)
+		agg.release()
+		return
+	}
+	lvl := agg.level(zap.InfoLevel)
+	if o.hasLevel {
+		lvl = o.level
 	}
+	l.emit(ctx, lvl, o.msg, agg)
 }
 
 func (l *Logger) Sync() error {
@@ -168,9 +313,10 @@ func ParseLevel(level string) zapcore.Level {
 	}
 }
 
-// NewWithZap 使用自定义 zap.Logger 对象包装
-func NewWithZap(l *zap.Logger) *Logger {
-	return &Logger{l}
+// NewWithZap 使用自定义 zap.Logger 对象包装，初始级别为原 core 的级别，SetLevel 只能在此基础上调高级别
+func NewWithZap(l *zap.Logger, opts ...LoggerOption) *Logger {
+	level := newLevelControl(LevelSpec{Level: zapcore.LevelOf(l.Core())})
+	return (&Logger{Logger: withLevel(l, level), level: level, named: &namedLoggers{}}).WithLoggerOptions(opts...)
 }
 
 // NewWithDispatch 自定义调度规则，使用自定义库作为日志切库，支持按时间切分日志
@@ -206,9 +352,10 @@ func NewWithDispatch(
 	if err != nil {
 		return nil, nil, err
 	}
-	// 初始化 zap 核心
-	zapLogger := zap.New(core)
+	// 初始化 zap 核心，各级别写入哪个文件由分发规则决定，初始级别为 Debug，可通过 SetLevel 调整
+	level := newLevelControl(LevelSpec{Level: zap.DebugLevel})
+	zapLogger := withLevel(zap.New(core), level)
 
-	return &Logger{zapLogger}, closeFn, nil
+	return &Logger{Logger: zapLogger, level: level, named: &namedLoggers{}}, closeFn, nil
 
 }
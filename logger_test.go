@@ -0,0 +1,344 @@
+package logit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newTestLogger 构建一个写入内存的 JSON 日志对象
+func newTestLogger(lvl zapcore.Level) (*Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	core := zapcore.NewCore(getEncoder(), zapcore.AddSync(buf), lvl)
+	return NewWithZap(zap.New(core)), buf
+}
+
+// decodeLines 将输出按行解析为 JSON 对象
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		m := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("unmarshal log line %q: %v", line, err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestLogger_Begin(t *testing.T) {
+	tests := []struct {
+		name      string
+		prepare   func(ctx context.Context)
+		err       error
+		wantLevel string
+		wantKeys  []string
+	}{
+		{
+			name: "info",
+			prepare: func(ctx context.Context) {
+				AddMetaField(ctx, String("trace_id", "abc"))
+				AddField(ctx, String("uid", "10001"))
+			},
+			wantLevel: "info",
+			wantKeys:  []string{"trace_id", "uid", "elapsed"},
+		},
+		{
+			name: "error",
+			prepare: func(ctx context.Context) {
+				AddField(ctx, String("uid", "10001"))
+			},
+			err:       errors.New("db failed"),
+			wantLevel: "error",
+			wantKeys:  []string{"uid", "elapsed", "error"},
+		},
+		{
+			name: "escalate_by_level_fields",
+			prepare: func(ctx context.Context) {
+				AddDebug(ctx, String("sql", "select 1"))
+				AddWarn(ctx, String("slow", "true"))
+			},
+			wantLevel: "warn",
+			wantKeys:  []string{"sql", "slow", "elapsed"},
+		},
+		{
+			name: "escalate_capped_at_error",
+			prepare: func(ctx context.Context) {
+				AddFatal(ctx, String("fatal", "true"))
+			},
+			wantLevel: "error",
+			wantKeys:  []string{"fatal", "elapsed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.DebugLevel)
+			ctx := NewContext(context.Background())
+			finish := logger.Begin(ctx, "order.create")
+			tt.prepare(ctx)
+			finish(tt.err)
+			finish(tt.err)
+
+			lines := decodeLines(t, out)
+			if len(lines) != 1 {
+				t.Fatalf("Begin() lines = %d, want = 1", len(lines))
+			}
+			if lines[0]["level"] != tt.wantLevel {
+				t.Errorf("Begin() level = %v, want = %v", lines[0]["level"], tt.wantLevel)
+			}
+			if lines[0]["msg"] != "order.create" {
+				t.Errorf("Begin() msg = %v, want = %v", lines[0]["msg"], "order.create")
+			}
+			for _, key := range tt.wantKeys {
+				if _, ok := lines[0][key]; !ok {
+					t.Errorf("Begin() missing key %q in %v", key, lines[0])
+				}
+			}
+		})
+	}
+}
+
+func TestLogger_Flush(t *testing.T) {
+	logger, out := newTestLogger(zap.DebugLevel)
+	ctx := NewContext(context.Background())
+	AddMetaField(ctx, String("trace_id", "abc"))
+	AddError(ctx, String("err_code", "E500"))
+	AddDebug(ctx, String("sql", "select 1"))
+	AddField(ctx, String("uid", "10001"))
+
+	logger.Flush(ctx, WithFlushMessage("summary"))
+	logger.Flush(ctx)
+
+	lines := decodeLines(t, out)
+	if len(lines) != 1 {
+		t.Fatalf("Flush() lines = %d, want = 1", len(lines))
+	}
+	if lines[0]["level"] != "error" || lines[0]["msg"] != "summary" {
+		t.Errorf("Flush() level = %v msg = %v, want = error summary", lines[0]["level"], lines[0]["msg"])
+	}
+	raw := out.String()
+	order := []string{`"trace_id"`, `"uid"`, `"sql"`, `"err_code"`}
+	for i := 1; i < len(order); i++ {
+		if strings.Index(raw, order[i-1]) > strings.Index(raw, order[i]) {
+			t.Errorf("Flush() order %s should be before %s: %s", order[i-1], order[i], raw)
+		}
+	}
+
+	out.Reset()
+	logger.Info(ctx, "after flush")
+	lines = decodeLines(t, out)
+	if lines[0]["uid"] != "10001" || lines[0]["trace_id"] != "abc" {
+		t.Errorf("Info() after Flush() = %v, want uid and trace_id", lines[0])
+	}
+
+	out.Reset()
+	AddField(ctx, String("uid", "10002"))
+	logger.Flush(ctx, WithFlushLevel(zap.WarnLevel), WithFlushReset(true))
+	lines = decodeLines(t, out)
+	if len(lines) != 1 || lines[0]["uid"] != "10002" || lines[0]["level"] != "warn" {
+		t.Fatalf("Flush() after update = %v, want uid=10002 level=warn", lines)
+	}
+	if _, ok := FindField(ctx, "uid"); ok {
+		t.Errorf("Flush() with reset should clear uid")
+	}
+	if _, ok := FindMetaField(ctx, "trace_id"); !ok {
+		t.Errorf("Flush() with reset should keep meta fields")
+	}
+}
+
+func TestLogger_FlushRemoved(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove func(ctx context.Context)
+		add    func(ctx context.Context)
+	}{
+		{
+			name:   "normal",
+			remove: func(ctx context.Context) { RemoveField(ctx, "status") },
+			add:    func(ctx context.Context) { AddField(ctx, Int("status", 200)) },
+		},
+		{
+			name:   "level",
+			remove: func(ctx context.Context) { RemoveField(ctx, "status") },
+			add:    func(ctx context.Context) { AddWarn(ctx, Int("status", 200)) },
+		},
+		{
+			name:   "reset level",
+			remove: func(ctx context.Context) { ResetLevel(ctx, zap.WarnLevel) },
+			add:    func(ctx context.Context) { AddWarn(ctx, Int("status", 200)) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.DebugLevel)
+			ctx := NewContext(context.Background())
+			tt.add(ctx)
+			logger.Flush(ctx)
+			tt.remove(ctx)
+			logger.Flush(ctx)
+			tt.add(ctx)
+			logger.Flush(ctx)
+
+			lines := decodeLines(t, out)
+			if len(lines) != 2 || lines[1]["status"] != float64(200) {
+				t.Errorf("Flush() after remove and add = %v, want status=200 in the second line", lines)
+			}
+		})
+	}
+}
+
+func TestLogger_FlushCategories(t *testing.T) {
+	logger, out := newTestLogger(zap.DebugLevel)
+	ctx := NewContext(context.Background())
+	AddField(ctx, Int("status", 200))
+	logger.Flush(ctx)
+	AddWarn(ctx, Int("status", 200))
+	logger.Flush(ctx)
+
+	lines := decodeLines(t, out)
+	if len(lines) != 2 || lines[1]["status"] != float64(200) || lines[1]["level"] != "warn" {
+		t.Errorf("Flush() = %v, want the warn field flushed separately from the normal field", lines)
+	}
+}
+
+func TestLogger_FlushDisabled(t *testing.T) {
+	tests := []struct {
+		name  string
+		flush func(l *Logger, ctx context.Context)
+	}{
+		{
+			name:  "flush",
+			flush: func(l *Logger, ctx context.Context) { l.Flush(ctx) },
+		},
+		{
+			name:  "flush reset",
+			flush: func(l *Logger, ctx context.Context) { l.Flush(ctx, WithFlushReset(true)) },
+		},
+		{
+			name:  "flush level",
+			flush: func(l *Logger, ctx context.Context) { l.Flush(ctx, WithFlushLevel(zap.InfoLevel)) },
+		},
+		{
+			name:  "begin",
+			flush: func(l *Logger, ctx context.Context) { l.Begin(ctx, "order.create")(nil) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.WarnLevel)
+			ctx := NewContext(context.Background())
+			AddField(ctx, String("uid", "10001"))
+			AddEvent(ctx, "db.query")
+
+			tt.flush(logger, ctx)
+			if out.Len() != 0 {
+				t.Fatalf("flush at disabled level wrote %s", out.String())
+			}
+			logger.Error(ctx, "failed")
+			lines := decodeLines(t, out)
+			if len(lines) != 1 || lines[0]["uid"] != "10001" {
+				t.Errorf("Error() after disabled flush = %v, want uid=10001", lines)
+			}
+			if _, ok := FindField(ctx, "uid"); !ok {
+				t.Errorf("disabled flush should keep uid")
+			}
+
+			out.Reset()
+			logger.Flush(ctx, WithFlushLevel(zap.WarnLevel))
+			lines = decodeLines(t, out)
+			if len(lines) != 1 || lines[0][EventsKey] == nil {
+				t.Errorf("Flush() after disabled flush = %v, want events", lines)
+			}
+		})
+	}
+}
+
+func TestLogger_Output(t *testing.T) {
+	tests := []struct {
+		name     string
+		log      func(l *Logger, ctx context.Context)
+		wantLine bool
+	}{
+		{
+			name:     "disabled",
+			log:      func(l *Logger, ctx context.Context) { l.Debug(ctx, "output") },
+			wantLine: false,
+		},
+		{
+			name:     "enabled",
+			log:      func(l *Logger, ctx context.Context) { l.Warn(ctx, "output") },
+			wantLine: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			core := zapcore.NewCore(getEncoder(), zapcore.AddSync(out), zap.InfoLevel)
+			logger := NewWithZap(zap.New(core, zap.AddCaller(), zap.AddCallerSkip(2)))
+
+			ctx := NewContext(context.Background())
+			SetBufferLimits(ctx, BufferLimits{MaxKeys: 1})
+			AddField(ctx, String("a", "1"))
+			AddField(ctx, String("b", "2"))
+
+			tt.log(logger, ctx)
+			lines := decodeLines(t, out)
+			if (len(lines) == 1) != tt.wantLine {
+				t.Fatalf("Output() lines = %v, want line = %v", lines, tt.wantLine)
+			}
+			if !tt.wantLine {
+				// 未开启的级别不会合并字段，丢弃计数留到下一条日志
+				logger.Info(ctx, "next")
+				lines = decodeLines(t, out)
+			}
+			if lines[0][DroppedFieldsKey] != float64(1) {
+				t.Errorf("Output() %s = %v, want = 1", DroppedFieldsKey, lines[0][DroppedFieldsKey])
+			}
+			if caller, _ := lines[0]["caller"].(string); !strings.Contains(caller, "logger_test.go") {
+				t.Errorf("Output() caller = %q, want logger_test.go", caller)
+			}
+		})
+	}
+}
+
+func BenchmarkLogger_Output(b *testing.B) {
+	core := zapcore.NewCore(getEncoder(), zapcore.AddSync(io.Discard), zap.DebugLevel)
+	logger := NewWithZap(zap.New(core))
+	for _, n := range benchKeyCounts {
+		b.Run("keys="+strconv.Itoa(n), func(b *testing.B) {
+			ctx := newBenchContext(n)
+			b.ReportAllocs()
+			for b.Loop() {
+				logger.Info(ctx, "bench")
+			}
+		})
+	}
+}
+
+// BenchmarkLogger_DebugDisabled 未开启 Debug 级别时，无论上下文中缓冲了多少字段都不应产生额外开销
+func BenchmarkLogger_DebugDisabled(b *testing.B) {
+	core := zapcore.NewCore(getEncoder(), zapcore.AddSync(io.Discard), zap.InfoLevel)
+	logger := NewWithZap(zap.New(core))
+	for _, n := range benchKeyCounts {
+		b.Run("keys="+strconv.Itoa(n), func(b *testing.B) {
+			ctx := newBenchContext(n)
+			AddDebug(ctx, String("sql", "select 1"))
+			b.ReportAllocs()
+			for b.Loop() {
+				logger.Debug(ctx, "bench", String("msg_id", "1"))
+			}
+		})
+	}
+}
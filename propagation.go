@@ -0,0 +1,239 @@
+package logit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// BaggageHeader W3C Baggage 规范定义的请求头
+const BaggageHeader = "baggage"
+
+const (
+	// defaultMaxMembers W3C Baggage 规范要求至少支持的成员数
+	defaultMaxMembers = 64
+	// defaultMaxBytes W3C Baggage 规范要求至少支持的总字节数
+	defaultMaxBytes = 8192
+)
+
+// Propagator 按 W3C Baggage 格式在服务间通过 HTTP 头传递元数据字段。
+// 只有 AllowKeys 中列出的元数据字段会被传递，接收方还原的字段均为字符串类型。
+type Propagator struct {
+	// Header 请求头名称，默认为 baggage
+	Header string
+	// AllowKeys 允许跨服务传递的元数据字段，为空时不传递任何字段
+	AllowKeys []string
+	// MaxMembers 请求头中最多的成员数，包括其他系统写入的成员，默认 64
+	MaxMembers int
+	// MaxBytes 请求头的最大字节数，包括其他系统写入的成员，默认 8192
+	MaxBytes int
+}
+
+// DefaultPropagator Inject 和 Extract 使用的默认配置，应在初始化阶段设置 AllowKeys
+var DefaultPropagator = Propagator{
+	Header:     BaggageHeader,
+	MaxMembers: defaultMaxMembers,
+	MaxBytes:   defaultMaxBytes,
+}
+
+// Inject 使用 DefaultPropagator 将上下文中允许传递的元数据字段写入 header，通常用于发起 HTTP 请求前
+func Inject(ctx context.Context, header http.Header) {
+	DefaultPropagator.Inject(ctx, header)
+}
+
+// Extract 使用 DefaultPropagator 从 header 中还原元数据字段，通常用于 HTTP 服务的入口
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return DefaultPropagator.Extract(ctx, header)
+}
+
+// Inject 将上下文中允许传递的元数据字段写入 header。
+// header 中已有的其他成员保留在前面，同名成员被替换；超出成员数或字节数限制的字段不再写入。
+// 只传递字符串、数字、布尔、耗时和时间类型的字段。
+func (p Propagator) Inject(ctx context.Context, header http.Header) {
+	buf := getBuf(ctx)
+	if buf == nil || header == nil || len(p.AllowKeys) == 0 {
+		return
+	}
+
+	buf.mu.RLock()
+	var own []string
+	keys := map[string]struct{}{}
+	for f := range buf.meta.all() {
+		if !slices.Contains(p.AllowKeys, f.Key) || !isBaggageKey(f.Key) {
+			continue
+		}
+		if v, ok := baggageValue(f); ok {
+			own = append(own, f.Key+"="+escapeBaggage(v))
+			keys[f.Key] = struct{}{}
+		}
+	}
+	buf.mu.RUnlock()
+	if len(own) == 0 {
+		return
+	}
+
+	var members []string
+	size := 0
+	add := func(member string) bool {
+		n := len(member)
+		if len(members) > 0 {
+			n++ // 分隔符 ","
+		}
+		if len(members) >= p.maxMembers() || size+n > p.maxBytes() {
+			return false
+		}
+		members = append(members, member)
+		size += n
+		return true
+	}
+	for _, member := range splitBaggage(header.Values(p.header())) {
+		if _, ok := keys[baggageKey(member)]; !ok {
+			add(member)
+		}
+	}
+	for _, member := range own {
+		if !add(member) {
+			break
+		}
+	}
+	header.Set(p.header(), strings.Join(members, ","))
+}
+
+// Extract 从 header 中还原允许传递的元数据字段，返回的上下文中已有日志容器时写入该容器，否则新建容器。
+// 超出成员数或字节数限制的部分以及无法解析的成员会被忽略。
+func (p Propagator) Extract(ctx context.Context, header http.Header) context.Context {
+	ctx = WithContext(ctx)
+	if ctx == nil || header == nil || len(p.AllowKeys) == 0 {
+		return ctx
+	}
+
+	var fields []zap.Field
+	size := 0
+	for i, member := range splitBaggage(header.Values(p.header())) {
+		if i > 0 {
+			size++ // 分隔符 ","
+		}
+		size += len(member)
+		if i >= p.maxMembers() || size > p.maxBytes() {
+			break
+		}
+		key := baggageKey(member)
+		if !slices.Contains(p.AllowKeys, key) {
+			continue
+		}
+		kv, _, _ := strings.Cut(member, ";")
+		_, raw, _ := strings.Cut(kv, "=")
+		value, err := url.PathUnescape(strings.TrimSpace(raw))
+		if err != nil {
+			continue
+		}
+		fields = append(fields, zap.String(key, value))
+	}
+	AddMetaFields(ctx, fields...)
+	return ctx
+}
+
+func (p Propagator) header() string {
+	if p.Header == "" {
+		return BaggageHeader
+	}
+	return p.Header
+}
+
+func (p Propagator) maxMembers() int {
+	if p.MaxMembers <= 0 {
+		return defaultMaxMembers
+	}
+	return p.MaxMembers
+}
+
+func (p Propagator) maxBytes() int {
+	if p.MaxBytes <= 0 {
+		return defaultMaxBytes
+	}
+	return p.MaxBytes
+}
+
+// splitBaggage 将多个请求头的值拆分为成员列表，忽略空成员
+func splitBaggage(values []string) []string {
+	var members []string
+	for _, v := range values {
+		for _, member := range strings.Split(v, ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				members = append(members, member)
+			}
+		}
+	}
+	return members
+}
+
+// baggageKey 返回成员的 key
+func baggageKey(member string) string {
+	key, _, _ := strings.Cut(member, "=")
+	return strings.TrimSpace(key)
+}
+
+// isBaggageKey 判断 key 是否为 RFC 7230 定义的 token
+func isBaggageKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// baggageValue 将字段值转换为字符串，不支持的类型返回 false
+func baggageValue(f zap.Field) (string, bool) {
+	switch v := fieldValue(f).(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case int32, int16, int8, uint64, uint32, uint16, uint8, uintptr:
+		return fmt.Sprint(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), true
+	case time.Duration:
+		return v.String(), true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+	default:
+		return "", false
+	}
+}
+
+// escapeBaggage 按 W3C Baggage 规范对值做百分号编码，只保留 baggage-octet 中的字符
+func escapeBaggage(v string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c > 0x20 && c < 0x7f && c != '"' && c != ',' && c != ';' && c != '\\' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package logit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestPropagator_Inject(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        Propagator
+		meta     []zap.Field
+		existing string
+		want     string
+	}{
+		{
+			name: "allow list",
+			p:    Propagator{AllowKeys: []string{"trace_id", "uid"}},
+			meta: []zap.Field{String("trace_id", "abc"), String("host", "h1"), Int("uid", 10001)},
+			want: "trace_id=abc,uid=10001",
+		},
+		{
+			name: "escape",
+			p:    Propagator{AllowKeys: []string{"name"}},
+			meta: []zap.Field{String("name", "张三, a;b%")},
+			want: "name=%E5%BC%A0%E4%B8%89%2C%20a%3Bb%25",
+		},
+		{
+			name:     "keep other members",
+			p:        Propagator{AllowKeys: []string{"trace_id"}},
+			meta:     []zap.Field{String("trace_id", "abc")},
+			existing: "vendor=x;p=1, trace_id=old",
+			want:     "vendor=x;p=1,trace_id=abc",
+		},
+		{
+			name:     "max members",
+			p:        Propagator{AllowKeys: []string{"a", "b"}, MaxMembers: 2},
+			meta:     []zap.Field{String("a", "1"), String("b", "2")},
+			existing: "vendor=x",
+			want:     "vendor=x,a=1",
+		},
+		{
+			name: "max bytes",
+			p:    Propagator{AllowKeys: []string{"a", "b"}, MaxBytes: 6},
+			meta: []zap.Field{String("a", "1"), String("b", "2")},
+			want: "a=1",
+		},
+		{
+			name: "custom header",
+			p:    Propagator{Header: "X-Logit-Meta", AllowKeys: []string{"d"}},
+			meta: []zap.Field{Duration("d", time.Second)},
+			want: "d=1s",
+		},
+		{
+			name: "unsupported type",
+			p:    Propagator{AllowKeys: []string{"obj"}},
+			meta: []zap.Field{Any("obj", map[string]int{"a": 1})},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewContext(context.Background())
+			AddMetaFields(ctx, tt.meta...)
+			header := http.Header{}
+			if tt.existing != "" {
+				header.Set(tt.p.header(), tt.existing)
+			}
+			tt.p.Inject(ctx, header)
+			if got := header.Get(tt.p.header()); got != tt.want {
+				t.Errorf("Inject() = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropagator_Extract(t *testing.T) {
+	p := Propagator{AllowKeys: []string{"trace_id", "name"}, MaxMembers: 3}
+	header := http.Header{}
+	header.Add(BaggageHeader, "trace_id=abc;ttl=1, secret=x")
+	header.Add(BaggageHeader, "name=%E5%BC%A0%20%2C, other=1, name=late")
+
+	ctx := p.Extract(context.Background(), header)
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{key: "trace_id", want: "abc", ok: true},
+		{key: "name", want: "张 ,", ok: true},
+		{key: "secret", ok: false},
+	}
+	for _, tt := range tests {
+		f, ok := FindMetaField(ctx, tt.key)
+		if ok != tt.ok || f.String != tt.want {
+			t.Errorf("Extract() %s = %q, %v, want = %q, %v", tt.key, f.String, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPropagator_RoundTrip(t *testing.T) {
+	p := Propagator{AllowKeys: []string{"trace_id", "user"}}
+	ctx := NewContext(context.Background())
+	AddMetaFields(ctx, String("trace_id", "abc-123"), String("user", "a=b;c"), String("host", "h1"))
+
+	header := http.Header{}
+	p.Inject(ctx, header)
+	got := Snapshot(p.Extract(context.Background(), header))
+	want := map[string]any{"trace_id": "abc-123", "user": "a=b;c"}
+	if len(got) != len(want) || got["trace_id"] != want["trace_id"] || got["user"] != want["user"] {
+		t.Errorf("round trip = %v, want = %v (header %q)", got, want, strings.Join(header.Values(BaggageHeader), ","))
+	}
+}
@@ -0,0 +1,174 @@
+package logit
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RecoverOption Recover 的可选参数
+type RecoverOption func(*recoverOptions)
+
+type recoverOptions struct {
+	msg     string
+	level   zapcore.Level
+	repanic bool
+	errp    *error
+}
+
+// WithRecoverMessage 设置日志的消息内容，默认为 "panic recovered"
+func WithRecoverMessage(msg string) RecoverOption {
+	return func(o *recoverOptions) {
+		o.msg = msg
+	}
+}
+
+// WithRecoverLevel 设置日志级别，默认为 Error。
+// 使用 Panic 或 Fatal 级别时只写入日志，不会由日志组件再次 panic 或退出进程。
+func WithRecoverLevel(lvl zapcore.Level) RecoverOption {
+	return func(o *recoverOptions) {
+		o.level = lvl
+	}
+}
+
+// WithRepanic 写完日志后是否以原始的值重新 panic
+func WithRepanic(repanic bool) RecoverOption {
+	return func(o *recoverOptions) {
+		o.repanic = repanic
+	}
+}
+
+// WithRecoverError 将 panic 转换为 *PanicError 写入 errp，通常传入函数具名返回值的地址
+func WithRecoverError(errp *error) RecoverOption {
+	return func(o *recoverOptions) {
+		o.errp = errp
+	}
+}
+
+// PanicError 由 panic 转换而来的错误
+type PanicError struct {
+	// Value panic 的原始值
+	Value any
+	// Stack panic 发生时的调用栈
+	Stack []StackFrame
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap panic 的值为 error 时返回该 error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// StackFrame 调用栈中的一帧
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+func (f StackFrame) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("func", f.Function)
+	enc.AddString("file", f.File)
+	enc.AddInt("line", f.Line)
+	return nil
+}
+
+// stackFrames 结构化的调用栈，输出为对象数组
+type stackFrames []StackFrame
+
+func (s stackFrames) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, f := range s {
+		if err := enc.AppendObject(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// panicStack 返回 panic 发生处的调用栈，去掉 Recover 自身以及 runtime 中处理 panic 的帧
+func panicStack() []StackFrame {
+	pcs := make([]uintptr, 64)
+	// 跳过 runtime.Callers、panicStack 和 Recover
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []StackFrame
+	for {
+		frame, more := frames.Next()
+		if len(stack) > 0 || !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, StackFrame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// Recover 捕获 panic 并写入一条包含上下文全部缓冲字段、panic 值和结构化调用栈的日志，随后同步写入磁盘。
+// 必须直接通过 defer 调用：
+//
+//	defer logger.Recover(ctx)
+//
+// 默认吞掉 panic，可通过 WithRepanic 重新 panic，或通过 WithRecoverError 转换为错误返回。
+// 缓冲字段的汇总方式与 Begin 相同，输出后会被标记为已输出。
+func (l *Logger) Recover(ctx context.Context, opts ...RecoverOption) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	o := recoverOptions{msg: "panic recovered", level: zap.ErrorLevel}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	stack := panicStack()
+	value := zap.Any("panic", r)
+	if err, ok := r.(error); ok {
+		value = zap.NamedError("panic", err)
+	}
+	agg := flushFields(ctx, false, l.merge, value, zap.Array("stack", stackFrames(stack)))
+	if o.level >= zap.ErrorLevel {
+		Commit(ctx)
+	}
+
+	// 直接通过 Core 写入，避免 Panic、Fatal 级别触发 zap 的 panic 或退出进程，调用位置取 panic 发生处
+	ent := zapcore.Entry{
+		Level:      o.level,
+		Time:       time.Now(),
+		LoggerName: l.Name(),
+		Message:    o.msg,
+	}
+	if len(stack) > 0 {
+		ent.Caller = zapcore.NewEntryCaller(0, stack[0].File, stack[0].Line, true)
+		ent.Caller.Function = stack[0].Function
+	}
+	core := l.Core()
+	if c, ok := core.(*levelCore); ok && controlOf(ctx).forces(o.level) {
+		core = c.Core
+	}
+	ce := core.Check(ent, nil)
+	if ce != nil {
+		agg.resolve()
+		ce.Write(agg.fields...)
+	}
+	agg.done(ce != nil)
+	agg.release()
+	_ = l.Sync()
+
+	if o.errp != nil {
+		*o.errp = &PanicError{Value: r, Stack: stack}
+	}
+	if o.repanic {
+		panic(r)
+	}
+}
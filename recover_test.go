@@ -0,0 +1,124 @@
+package logit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var errBoom = errors.New("boom")
+
+func TestLogger_Recover(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      func(errp *error) []RecoverOption
+		value     any
+		wantLevel string
+		wantPanic string
+		repanic   bool
+	}{
+		{
+			name:      "default",
+			value:     "oops",
+			wantLevel: "error",
+			wantPanic: "oops",
+		},
+		{
+			name: "panic level",
+			opts: func(*error) []RecoverOption {
+				return []RecoverOption{WithRecoverLevel(zap.PanicLevel), WithRecoverMessage("crash")}
+			},
+			value:     "oops",
+			wantLevel: "panic",
+			wantPanic: "oops",
+		},
+		{
+			name: "error",
+			opts: func(errp *error) []RecoverOption {
+				return []RecoverOption{WithRecoverError(errp)}
+			},
+			value:     errBoom,
+			wantLevel: "error",
+			wantPanic: "boom",
+		},
+		{
+			name: "repanic",
+			opts: func(*error) []RecoverOption {
+				return []RecoverOption{WithRepanic(true)}
+			},
+			value:     "oops",
+			wantLevel: "error",
+			wantPanic: "oops",
+			repanic:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, out := newTestLogger(zap.InfoLevel)
+			ctx := NewContext(context.Background())
+			AddMetaField(ctx, String("trace_id", "abc"))
+			AddField(ctx, String("uid", "10001"))
+			AddDebug(ctx, String("sql", "select 1"))
+
+			var err error
+			var opts []RecoverOption
+			if tt.opts != nil {
+				opts = tt.opts(&err)
+			}
+			var repanicked any
+			func() {
+				defer func() { repanicked = recover() }()
+				func() {
+					defer logger.Recover(ctx, opts...)
+					panic(tt.value)
+				}()
+			}()
+
+			if (repanicked != nil) != tt.repanic {
+				t.Errorf("Recover() repanicked = %v, want = %v", repanicked, tt.repanic)
+			}
+			lines := decodeLines(t, out)
+			if len(lines) != 1 {
+				t.Fatalf("Recover() lines = %d, want = 1", len(lines))
+			}
+			line := lines[0]
+			if line["level"] != tt.wantLevel || line["panic"] != tt.wantPanic {
+				t.Errorf("Recover() level = %v, panic = %v, want = %s, %s", line["level"], line["panic"], tt.wantLevel, tt.wantPanic)
+			}
+			for _, key := range []string{"trace_id", "uid", "sql"} {
+				if _, ok := line[key]; !ok {
+					t.Errorf("Recover() missing buffered field %s", key)
+				}
+			}
+			stack, _ := line["stack"].([]any)
+			if len(stack) == 0 {
+				t.Fatalf("Recover() stack = %v, want frames", line["stack"])
+			}
+			top, _ := stack[0].(map[string]any)
+			if fn, _ := top["func"].(string); !strings.Contains(fn, "TestLogger_Recover") {
+				t.Errorf("Recover() top frame = %v, want panic site", top)
+			}
+
+			if tt.opts != nil && tt.value == errBoom {
+				var pe *PanicError
+				if !errors.As(err, &pe) || !errors.Is(err, errBoom) || len(pe.Stack) == 0 {
+					t.Errorf("Recover() err = %v, want *PanicError wrapping boom", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLogger_RecoverNoPanic(t *testing.T) {
+	logger, out := newTestLogger(zapcore.DebugLevel)
+	func() {
+		defer logger.Recover(context.Background())
+	}()
+	if out.Len() != 0 {
+		t.Errorf("Recover() without panic wrote %s", out.String())
+	}
+}
@@ -16,70 +16,32 @@ type ZapHandler struct {
 	fields []zap.Field
 }
 
-func NewZapHandler(logger *Logger) *ZapHandler {
-	return &ZapHandler{logger: logger}
+// NewZapHandler 使用 Logger 创建 slog.Handler，opts 可以为 Handler 单独追加配置，例如 WithExtractor
+func NewZapHandler(logger *Logger, opts ...LoggerOption) *ZapHandler {
+	return &ZapHandler{logger: logger.WithLoggerOptions(opts...)}
 }
 
-func (h *ZapHandler) Enabled(_ context.Context, level slog.Level) bool {
+func (h *ZapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	ctl := controlOf(ctx)
+	if lvl := levelToZapLevel(level); ctl.tail.captures(lvl) || ctl.forces(lvl) {
+		return true
+	}
 	switch level {
 	case slog.LevelDebug:
-		return h.logger.Core().Enabled(zap.DebugLevel)
+		return h.logger.enabled(zap.DebugLevel)
 	case slog.LevelInfo:
-		return h.logger.Core().Enabled(zap.InfoLevel)
+		return h.logger.enabled(zap.InfoLevel)
 	case slog.LevelWarn:
-		return h.logger.Core().Enabled(zap.WarnLevel)
+		return h.logger.enabled(zap.WarnLevel)
 	case slog.LevelError:
-		return h.logger.Core().Enabled(zap.ErrorLevel)
+		return h.logger.enabled(zap.ErrorLevel)
 	}
 	return true
 }
 
 func (h *ZapHandler) Handle(ctx context.Context, record slog.Record) error {
-	buf := getBuf(ctx)
-
-	fields := make([]zap.Field, 0, len(h.fields)+record.NumAttrs())
+	fields := make([]zap.Field, 0, len(h.fields)+record.NumAttrs()+1)
 
-	if buf != nil {
-		fkv := make(map[string]zap.Field, len(buf.normalFields)+len(buf.levelFields))
-
-		// 1）保证元数据顺序
-		for _, k := range buf.metaOrder {
-			if f, ok := buf.metaFields[k]; ok {
-				fields = append(fields, f)
-				fkv[k] = f
-			}
-		}
-		// 这里将 slog 的日志级别转换为 zap 的级别
-		lvl := levelToZapLevel(record.Level)
-
-		if lvl == zap.InfoLevel {
-			// 2）普通字段顺序
-			for _, k := range buf.normalOrder {
-				if f, ok := buf.normalFields[k]; ok {
-					if _, ok := fkv[k]; !ok {
-						// 这里不能覆盖元数据字段
-						fields = append(fields, f)
-					}
-				}
-			}
-		}
-
-		// 3）level 字段严格保持顺序
-		for _, k := range buf.levelOrder[lvl] {
-			if f, ok := buf.levelFields[lvl][k]; ok {
-				if _, ok := fkv[k]; !ok {
-					// 这里不能覆盖元数据字段
-					fields = append(fields, f)
-				}
-			}
-		}
-		// 4）最后补充字段
-		for _, field := range fields {
-			if _, ok := fkv[field.Key]; !ok {
-				fields = append(fields, field)
-			}
-		}
-	}
 	// existing fields
 	fields = append(fields, h.fields...)
 
@@ -89,8 +51,6 @@ func (h *ZapHandler) Handle(ctx context.Context, record slog.Record) error {
 		return true
 	})
 
-	entry := h.logger.WithOptions()
-
 	// source information
 	if record.PC != 0 {
 		fn := runtime.FuncForPC(record.PC)
@@ -98,17 +58,27 @@ func (h *ZapHandler) Handle(ctx context.Context, record slog.Record) error {
 		fields = append(fields, zap.String("caller", file+":"+toString(line)))
 	}
 
-	switch record.Level {
-	case slog.LevelDebug:
-		entry.Debug(record.Message, fields...)
-	case slog.LevelInfo:
-		entry.Info(record.Message, fields...)
-	case slog.LevelWarn:
-		entry.Warn(record.Message, fields...)
-	case slog.LevelError:
-		entry.Error(record.Message, fields...)
-	default:
-		entry.Info(record.Message, fields...)
+	// 这里将 slog 的日志级别转换为 zap 的级别，并合并上下文中的字段
+	lvl := levelToZapLevel(record.Level)
+	ctl := controlOf(ctx)
+	if lvl >= zap.ErrorLevel {
+		ctl.tail.commit()
+	}
+	entry, fields, m := withFields(ctx, h.logger.Logger, lvl, h.logger.merge, fields...)
+	defer m.release()
+	if ctl.forces(lvl) && !h.logger.enabled(lvl) {
+		entry = bypassLevel(entry)
+	}
+	capture := ctl.tail.captures(lvl)
+	if capture {
+		entry = ctl.tail.wrap(entry)
+	}
+
+	if ce := entry.Check(lvl, record.Message); ce != nil {
+		if !capture {
+			m.resolve()
+		}
+		ce.Write(fields...)
 	}
 
 	return nil
@@ -168,8 +138,6 @@ func levelToZapLevel(level slog.Level) zapcore.Level {
 // NewSlogLogger 将 zap 日志组件包装为 slog 内置日志组件
 func NewSlogLogger(core zapcore.Core, options ...zap.Option) *slog.Logger {
 	logger := zap.New(core).WithOptions(options...)
-	handler := NewZapHandler(&Logger{
-		Logger: logger,
-	})
+	handler := NewZapHandler(NewWithZap(logger))
 	return slog.New(handler)
 }
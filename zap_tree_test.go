@@ -4,6 +4,7 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/lifei6671/rotatefiles"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -53,8 +54,8 @@ func TestBuildDispatchCore(t *testing.T) {
 						},
 					},
 				},
-				writerBuilder: func(ruleName, filename string, opts ...ZapWriterOptions) (zapcore.WriteSyncer, error) {
-					return nil, errors.New("test error")
+				writerBuilder: func(ruleName, filename string, opts ...ZapWriterOptions) (zapcore.WriteSyncer, rotatefiles.RotateGenerator, error) {
+					return nil, nil, errors.New("test error")
 				},
 			},
 